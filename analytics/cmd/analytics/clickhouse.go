@@ -0,0 +1,50 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/clickhouse"
+	"analytics/internal/services/events"
+	"analytics/internal/services/retention"
+	"context"
+	urlevents "events"
+	"log/slog"
+)
+
+// mirroredVisits stores visits in ClickHouse as well as in the primary
+// repository. ClickHouse goes first: it takes redeliveries, while the
+// primary drops them, so a visit the primary stored is never missing from
+// ClickHouse after a retry.
+type mirroredVisits struct {
+	events.Repository
+	clickhouse *clickhouse.Repository
+}
+
+func (m mirroredVisits) SaveVisitedEvent(ctx context.Context, event urlevents.UrlEvent) error {
+	if err := m.clickhouse.SaveVisitedEvent(ctx, event); err != nil {
+		return err
+	}
+	return m.Repository.SaveVisitedEvent(ctx, event)
+}
+
+// retentionServices returns the retention of the primary repository and, when
+// ch is set, of ClickHouse. Both stores hold the same visits, so only the
+// primary archives them before they expire.
+func retentionServices(cfg *config.Config, logger *slog.Logger, primary retention.Repository, ch *clickhouse.Repository) ([]*retention.Service, error) {
+	service, err := retention.New(cfg.Retention, logger, primary)
+	if err != nil {
+		return nil, err
+	}
+	services := []*retention.Service{service}
+
+	if ch != nil {
+		chCfg := cfg.Retention
+		chCfg.ArchiveDir = ""
+		service, err := retention.New(chCfg, logger.With("store", "clickhouse"), ch)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+
+	return services, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/transport/kafka"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var errDLQUsage = errors.New("usage: analytics dlq inspect|replay [-limit N]")
+
+// runDLQ inspects or replays the dead-letter topic. Inspect prints the
+// pending messages as JSON lines; replay sends them back to the topic they
+// came from, typically after the bug that made them fail was fixed.
+func runDLQ(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errDLQUsage
+	}
+
+	fs := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "maximum number of messages, 0 reads until the topic is idle")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	admin, err := kafka.NewDLQAdmin(cfg)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	switch args[0] {
+	case "inspect":
+		enc := json.NewEncoder(os.Stdout)
+		var count int
+		err := admin.Inspect(ctx, *limit, func(dl kafka.DeadLetter) error {
+			count++
+			return enc.Encode(dl)
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("dlq inspected", "topic", cfg.MsgBroker.DLQTopic, "pending", count)
+	case "replay":
+		replayed, err := admin.Replay(ctx, *limit)
+		logger.Info("dlq replayed", "topic", cfg.MsgBroker.DLQTopic, "replayed", replayed)
+		if err != nil {
+			return err
+		}
+	default:
+		return errDLQUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/config"
+	"analytics/internal/repository/postgres"
+	"analytics/internal/services/export"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errExportUsage = errors.New("usage: analytics export (-short-url ALIAS | -owner USER) [-from TIME] [-to TIME] [-format csv|ndjson|parquet] [-columns a,b] [-gzip] [-o FILE]")
+
+// runExport writes raw visits to a file or stdout for the data team. Unlike
+// the export endpoint it is not limited to the caller's links.
+func runExport(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	shortURL := fs.String("short-url", "", "export the visits of this link")
+	owner := fs.String("owner", "", "export the visits of the links created by this user")
+	fromFlag := fs.String("from", "", "first visit time to export, RFC 3339 or YYYY-MM-DD")
+	toFlag := fs.String("to", "", "visit time (exclusive) to stop at, RFC 3339 or YYYY-MM-DD")
+	format := fs.String("format", archive.FormatCSV, "csv, ndjson or parquet")
+	columns := fs.String("columns", "", "comma separated columns to export, all when empty: "+strings.Join(archive.Columns, ","))
+	gzipFlag := fs.Bool("gzip", false, "gzip the output")
+	output := fs.String("o", "-", "file to write, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *shortURL == "" && *owner == "" {
+		return errExportUsage
+	}
+
+	q := export.Query{ShortURL: *shortURL, Owner: *owner, Format: *format, Gzip: *gzipFlag}
+	if *columns != "" {
+		q.Columns = strings.Split(*columns, ",")
+	}
+	var err error
+	if q.From, err = parseFlagTime(*fromFlag); err != nil {
+		return err
+	}
+	if q.To, err = parseFlagTime(*toFlag); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	if *output == "-" {
+		// The logger writes to stdout too, so nothing is logged here.
+		_, err := export.New(repo).Export(ctx, os.Stdout, q)
+		return err
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	n, err := export.New(repo).Export(ctx, f, q)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	logger.Info("visits exported", "visits", n, "format", q.Format, "output", *output)
+
+	return nil
+}
+
+func parseFlagTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -2,10 +2,24 @@ package main
 
 import (
 	"analytics/internal/config"
+	"analytics/internal/repository/clickhouse"
 	"analytics/internal/repository/postgres"
+	"analytics/internal/repository/redis"
+	"analytics/internal/services/events"
+	"analytics/internal/services/export"
+	"analytics/internal/services/live"
+	"analytics/internal/services/stats"
+	httpserver "analytics/internal/transport/http"
+	"analytics/internal/transport/kafka"
 	"context"
-	"log/slog"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"platform/logging"
+	"platform/tracing"
+	"syscall"
 
 	"github.com/joho/godotenv"
 )
@@ -18,15 +32,62 @@ func main() {
 
 	cfg := config.MustLoad()
 
-	logger := slog.New(slog.NewJSONHandler(
-		os.Stdout,
-		&slog.HandlerOptions{
-			AddSource: true,
-			Level:     slog.LevelInfo,
-		}),
-	)
+	logger := logging.SetupLogger(cfg.Env)
+
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(cfg, logger, os.Args[2:])
+		case "seed":
+			err = runSeed(cfg, logger, os.Args[2:])
+		case "dlq":
+			err = runDLQ(cfg, logger, os.Args[2:])
+		case "rollups":
+			err = runRollups(cfg, logger, os.Args[2:])
+		case "retention":
+			err = runRetention(cfg, logger, os.Args[2:])
+		case "export":
+			err = runExport(cfg, logger, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			logger.Error("command failed", "command", os.Args[1], "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.DB.AutoMigrate {
+		logger.Info("applying migrations")
+		if err := runMigrate(cfg, logger, []string{"up"}); err != nil {
+			logger.Error("failed to apply migrations", "err", err)
+			os.Exit(1)
+		}
+		if cfg.ClickHouse.Addr != "" {
+			if err := runMigrate(cfg, logger, []string{"clickhouse", "up"}); err != nil {
+				logger.Error("failed to apply clickhouse migrations", "err", err)
+				os.Exit(1)
+			}
+		}
+	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		ServiceName: cfg.AppName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Error("failed to init tracing", "err", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	repository, err := postgres.New(ctx, cfg)
 	if err != nil {
@@ -36,4 +97,89 @@ func main() {
 	defer repository.Close()
 
 	logger.Info("repository created")
+
+	var store events.Repository = repository
+	var visits *clickhouse.Repository
+	if cfg.ClickHouse.Addr != "" {
+		visits, err = clickhouse.New(ctx, cfg)
+		if err != nil {
+			logger.Error("failed to init clickhouse", "err", err)
+			os.Exit(1)
+		}
+		defer visits.Close()
+		store = mirroredVisits{Repository: repository, clickhouse: visits}
+	}
+
+	// Unique visitors are counted by the Redis sketches the consumer feeds,
+	// or else by ClickHouse from the visits it stores. Without either they
+	// are not counted.
+	var (
+		visitors events.UniqueCounter
+		uniques  stats.UniqueCounter
+	)
+	switch {
+	case cfg.Redis.Addr != "":
+		redisUniques, err := redis.New(cfg)
+		if err != nil {
+			logger.Error("failed to init unique visitor counter", "err", err)
+			os.Exit(1)
+		}
+		defer redisUniques.Close()
+		visitors, uniques = redisUniques, redisUniques
+	case visits != nil:
+		uniques = visits
+	}
+
+	hub := live.NewHub(cfg.Live.Buffer)
+	eventService := events.New(logger, store, visitors, hub)
+
+	deadLetters, err := kafka.NewDeadLetters(cfg)
+	if err != nil {
+		logger.Error("failed to init dead-letter producer", "err", err)
+		os.Exit(1)
+	}
+	defer deadLetters.Close()
+
+	consumer, err := kafka.NewConsumer(cfg, logger, eventService, deadLetters)
+	if err != nil {
+		logger.Error("failed to init kafka consumer", "err", err)
+		os.Exit(1)
+	}
+	defer consumer.Close()
+
+	if cfg.Retention.Days > 0 {
+		services, err := retentionServices(cfg, logger, repository, visits)
+		if err != nil {
+			logger.Error("failed to init retention", "err", err)
+			os.Exit(1)
+		}
+		for _, service := range services {
+			go service.Schedule(ctx, cfg.Retention.Interval)
+		}
+	}
+
+	httpServer := httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repository, uniques), hub, export.New(repository))
+	go func() {
+		logger.Info("server started", "address", cfg.HttpServer.Address)
+
+		if err := httpServer.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("failed to start server", "err", err)
+			stop()
+		}
+	}()
+
+	logger.Info("consumer started", "topic", cfg.MsgBroker.Topic, "group", cfg.MsgBroker.GroupID)
+
+	if err := consumer.Run(ctx); err != nil {
+		logger.Error("consumer stopped", "err", err)
+	} else {
+		logger.Info("consumer stopped gracefully")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HttpServer.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Stop(shutdownCtx); err != nil {
+		logger.Error("failed to stop server", "err", err)
+	}
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/clickhouse"
+	"analytics/internal/repository/postgres"
+	"errors"
+	"log/slog"
+	"platform/schema"
+)
+
+var errMigrateUsage = errors.New("usage: analytics migrate [clickhouse] up|down [N|all]|version|force VERSION")
+
+// runMigrate migrates Postgres, or ClickHouse when the first argument is
+// "clickhouse".
+func runMigrate(cfg *config.Config, logger *slog.Logger, args []string) error {
+	open := func() (*schema.Migrator, error) {
+		return postgres.NewMigrator(cfg, logger)
+	}
+	if len(args) > 0 && args[0] == "clickhouse" {
+		args = args[1:]
+		open = func() (*schema.Migrator, error) {
+			if cfg.ClickHouse.Addr == "" {
+				return nil, errors.New("CLICKHOUSE_ADDR is not set")
+			}
+			return clickhouse.NewMigrator(cfg, logger)
+		}
+	}
+
+	err := schema.Run(args, logger, open)
+	if errors.Is(err, schema.ErrUsage) {
+		return errMigrateUsage
+	}
+
+	return err
+}
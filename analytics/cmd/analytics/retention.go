@@ -0,0 +1,61 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/clickhouse"
+	"analytics/internal/repository/postgres"
+	"analytics/internal/services/retention"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var errRetentionUsage = errors.New("usage: analytics retention run")
+
+// runRetention applies the retention policy once, for use from cron when the
+// service itself runs with RETENTION_DAYS unset.
+func runRetention(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if len(args) != 1 || args[0] != "run" {
+		return errRetentionUsage
+	}
+	if cfg.Retention.Days == 0 {
+		return errors.New("RETENTION_DAYS is not set")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	var visits *clickhouse.Repository
+	if cfg.ClickHouse.Addr != "" {
+		visits, err = clickhouse.New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer visits.Close()
+	}
+
+	services, err := retentionServices(cfg, logger, repo, visits)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, service := range services {
+		if err := service.Run(ctx, now); err != nil {
+			return err
+		}
+	}
+	logger.Info("retention applied", "mode", cfg.Retention.Mode, "before", retention.Cutoff(cfg.Retention.Days, now).Format(time.DateOnly))
+
+	return nil
+}
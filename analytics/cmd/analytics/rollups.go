@@ -0,0 +1,74 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/postgres"
+	"analytics/internal/services/retention"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var errRollupsUsage = errors.New("usage: analytics rollups backfill -from YYYY-MM-DD [-to YYYY-MM-DD]")
+
+// runRollups rebuilds the visit rollups from the raw events, one day per
+// transaction, e.g. after the rollup definition changed or events were
+// loaded around the consumer.
+func runRollups(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if len(args) == 0 || args[0] != "backfill" {
+		return errRollupsUsage
+	}
+
+	fs := flag.NewFlagSet("rollups backfill", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "first day to rebuild, YYYY-MM-DD")
+	toFlag := fs.String("to", time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly), "last day (exclusive) to rebuild, YYYY-MM-DD")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *fromFlag == "" {
+		return errRollupsUsage
+	}
+	from, err := time.Parse(time.DateOnly, *fromFlag)
+	if err != nil {
+		return err
+	}
+	to, err := time.Parse(time.DateOnly, *toFlag)
+	if err != nil {
+		return err
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("-from %s is not before -to %s", *fromFlag, *toFlag)
+	}
+	// Rebuilding a day whose raw events were deleted would zero its rollups.
+	if cfg.Retention.Days > 0 && cfg.Retention.Mode == retention.ModeDelete {
+		if cutoff := retention.Cutoff(cfg.Retention.Days, time.Now()); from.Before(cutoff) {
+			return fmt.Errorf("raw events before %s are expired, -from must not be earlier", cutoff.Format(time.DateOnly))
+		}
+	}
+
+	ctx := context.Background()
+
+	repo, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	var total int64
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		n, err := repo.Backfill(ctx, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		total += n
+		logger.Info("rollups rebuilt", "day", day.Format(time.DateOnly), "rows", n)
+	}
+
+	logger.Info("backfill finished", "from", *fromFlag, "to", *toFlag, "rows", total)
+
+	return nil
+}
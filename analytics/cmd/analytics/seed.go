@@ -0,0 +1,102 @@
+package main
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository"
+	"analytics/internal/repository/postgres"
+	"context"
+	"errors"
+	"events"
+	"events/seed"
+	"flag"
+	"log/slog"
+	"time"
+)
+
+const seedProgressEvery = 10000
+
+func runSeed(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	urls := fs.Int("urls", 1000, "number of urls to generate")
+	visits := fs.Int("visits", 100000, "number of visit events to generate")
+	owners := fs.Int("owners", 20, "number of distinct link owners")
+	days := fs.Int("days", 30, "number of days the visits are spread over")
+	end := fs.String("end", time.Now().UTC().Format(time.DateOnly), "last day (exclusive) of the generated visits, YYYY-MM-DD")
+	seedValue := fs.Uint64("seed", 1, "random seed, the same seed and end date produce the same data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	endDate, err := time.Parse(time.DateOnly, *end)
+	if err != nil {
+		return err
+	}
+	start := endDate.AddDate(0, 0, -*days)
+
+	ctx := context.Background()
+
+	repo, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	generator := seed.NewGenerator(*seedValue)
+	links := generator.URLs(*urls)
+
+	// Rerunning with the same seed regenerates the same event IDs, so
+	// events stored by an earlier run are skipped.
+	var skipped int
+	save := func(err error) error {
+		if errors.Is(err, repository.ErrDuplicateEvent) {
+			skipped++
+			return nil
+		}
+		return err
+	}
+
+	for _, event := range generator.Created(links, generator.Owners(*owners), start) {
+		if err := save(repo.SaveCreatedEvent(ctx, event)); err != nil {
+			return err
+		}
+	}
+	logger.Info("created events seeded", "count", len(links))
+
+	// Visits are generated as they are stored, so memory stays flat
+	// whatever the number of visits.
+	batch := make([]events.UrlEvent, 0, postgres.MaxVisitBatch)
+	var done int
+	flush := func() error {
+		n, err := repo.SaveVisitedEvents(ctx, batch)
+		if err != nil {
+			return err
+		}
+		skipped += len(batch) - int(n)
+		prev := done
+		done += len(batch)
+		batch = batch[:0]
+
+		if done/seedProgressEvery > prev/seedProgressEvery {
+			logger.Info("seeding visits", "done", done, "total", *visits)
+		}
+		return nil
+	}
+
+	for event := range generator.Visits(links, *visits, *days, endDate) {
+		batch = append(batch, event)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("seed finished", "seed", *seedValue, "urls", len(links), "visits", *visits, "skipped", skipped, "from", start, "to", endDate)
+
+	return nil
+}
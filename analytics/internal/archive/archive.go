@@ -0,0 +1,209 @@
+// Package archive writes raw visit records as CSV, NDJSON or Parquet files.
+package archive
+
+import (
+	"analytics/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroup bounds the rows the Parquet writer buffers before writing
+// them out, so large files are streamed.
+const parquetRowGroup = 64 * 1024
+
+var (
+	ErrUnknownFormat = errors.New("unknown archive format")
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Columns lists the columns of a visit record in the order they are written,
+// named as in the NDJSON and Parquet output.
+var Columns = columnNames()
+
+// Writer encodes visit records to an io.Writer. Close flushes buffered
+// records and writes trailers, but does not close the underlying writer.
+type Writer interface {
+	Write(records ...models.VisitRecord) error
+	Close() error
+}
+
+// NewWriter returns a writer of format. The records are reduced to columns,
+// in that order, or written whole when no column is given.
+func NewWriter(format string, w io.Writer, columns ...string) (Writer, error) {
+	p, err := newProjection(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w), p: p}, nil
+	case FormatNDJSON:
+		return ndjsonWriter{enc: json.NewEncoder(w), p: p}, nil
+	case FormatParquet:
+		schema := parquet.SchemaOf(reflect.New(p.typ).Interface())
+		return parquetWriter{
+			w: parquet.NewWriter(w, schema, parquet.Compression(&parquet.Zstd), parquet.MaxRowsPerRowGroup(parquetRowGroup)),
+			p: p,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// projection maps visit records onto a struct type holding only the
+// selected fields, with their tags, so every format names and encodes the
+// columns the same way as for whole records.
+type projection struct {
+	typ    reflect.Type
+	fields []int
+	names  []string
+}
+
+func newProjection(columns []string) (*projection, error) {
+	if len(columns) == 0 {
+		columns = Columns
+	}
+
+	t := reflect.TypeFor[models.VisitRecord]()
+	p := &projection{names: columns}
+	fields := make([]reflect.StructField, 0, len(columns))
+	for _, name := range columns {
+		i := slices.Index(Columns, name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, name)
+		}
+		if slices.Index(p.fields, i) >= 0 {
+			return nil, fmt.Errorf("%w: %q is selected twice", ErrUnknownColumn, name)
+		}
+		p.fields = append(p.fields, i)
+		fields = append(fields, t.Field(i))
+	}
+	p.typ = reflect.StructOf(fields)
+
+	return p, nil
+}
+
+// project returns a pointer to the projection of r.
+func (p *projection) project(r models.VisitRecord) reflect.Value {
+	src := reflect.ValueOf(r)
+	dst := reflect.New(p.typ)
+	for i, f := range p.fields {
+		dst.Elem().Field(i).Set(src.Field(f))
+	}
+	return dst
+}
+
+func columnNames() []string {
+	t := reflect.TypeFor[models.VisitRecord]()
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return names
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	p      *projection
+	header bool
+}
+
+func (w *csvWriter) Write(records ...models.VisitRecord) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(w.p.fields))
+	for _, r := range records {
+		v := w.p.project(r).Elem()
+		for i := range row {
+			row[i] = csvValue(v.Field(i))
+		}
+		if err := w.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the header when there was no record, so an empty export is
+// still a valid file.
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.w.Write(w.p.names)
+}
+
+func csvValue(v reflect.Value) string {
+	switch v := v.Interface().(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+	p   *projection
+}
+
+func (w ndjsonWriter) Write(records ...models.VisitRecord) error {
+	for _, r := range records {
+		if err := w.enc.Encode(w.p.project(r).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w ndjsonWriter) Close() error {
+	return nil
+}
+
+type parquetWriter struct {
+	w *parquet.Writer
+	p *projection
+}
+
+func (w parquetWriter) Write(records ...models.VisitRecord) error {
+	for _, r := range records {
+		if err := w.w.Write(w.p.project(r).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w parquetWriter) Close() error {
+	return w.w.Close()
+}
@@ -1,20 +1,120 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type Config struct {
-	AppName string `envconfig:"NAME" required:"true"`
-	Env     string `envconfig:"ENV" required:"true"`
-	Debug   bool   `envconfig:"DEBUG" default:"false"`
-	DB      DB
+	AppName     string `envconfig:"NAME" required:"true"`
+	Env         string `envconfig:"ENV" required:"true"`
+	Debug       bool   `envconfig:"DEBUG" default:"false"`
+	HttpServer  HttpServer
+	DB          DB
+	ClickHouse  ClickHouse
+	MsgBroker   MsgBroker
+	Retention   Retention
+	Redis       Redis
+	Uniques     Uniques
+	Live        Live
+	Leaderboard Leaderboard
+	Tracing     Tracing
+}
+
+type HttpServer struct {
+	Address         string        `envconfig:"HTTP_ADDRESS" default:":8081"`
+	TimeOut         time.Duration `envconfig:"HTTP_TIMEOUT" default:"5s"`
+	IdleTimeout     time.Duration `envconfig:"HTTP_IDLE_TIMEOUT" default:"60s"`
+	ShutdownTimeout time.Duration `envconfig:"HTTP_SHUTDOWN_TIMEOUT" default:"10s"`
+	// AuthSecret verifies the bearer tokens identifying the callers of the
+	// live streams and exports: HS256 JWTs with the user ID as subject and
+	// an expiry. Links with an owner are limited to that user, so without a
+	// secret only links created anonymously can be followed and exported.
+	AuthSecret string `envconfig:"HTTP_AUTH_SECRET"`
 }
 
 type DB struct {
-	Host     string `envconfig:"POSTGRES_HOST" required:"true"`
-	Port     string `envconfig:"POSTGRES_PORT" required:"true"`
-	Name     string `envconfig:"POSTGRES_DB" required:"true"`
-	Username string `envconfig:"POSTGRES_USER" required:"true"`
-	Password string `envconfig:"POSTGRES_PASSWORD"`
+	Host        string `envconfig:"POSTGRES_HOST" required:"true"`
+	Port        string `envconfig:"POSTGRES_PORT" required:"true"`
+	Name        string `envconfig:"POSTGRES_DB" required:"true"`
+	Username    string `envconfig:"POSTGRES_USER" required:"true"`
+	Password    string `envconfig:"POSTGRES_PASSWORD"`
+	AutoMigrate bool   `envconfig:"DB_AUTO_MIGRATE" default:"false"`
+}
+
+// ClickHouse is an optional second store of the raw visits, for queries
+// over more history than Postgres keeps comfortably. Visits are written to
+// it as well when Addr is set, and retention applies to both stores.
+type ClickHouse struct {
+	Addr     string `envconfig:"CLICKHOUSE_ADDR"`
+	Name     string `envconfig:"CLICKHOUSE_DB" default:"analytics"`
+	Username string `envconfig:"CLICKHOUSE_USER" default:"default"`
+	Password string `envconfig:"CLICKHOUSE_PASSWORD"`
+}
+
+type MsgBroker struct {
+	Addr    []string `envconfig:"KAFKA_ADDRESS" required:"true"`
+	GroupID string   `envconfig:"KAFKA_GROUP_ID" default:"analytics"`
+	Topic   string   `envconfig:"KAFKA_TOPIC" default:"url_events"`
+	// DLQTopic receives the messages that could not be stored.
+	DLQTopic string `envconfig:"KAFKA_DLQ_TOPIC" default:"url_events.dlq"`
+	// A message that failed with a transient error, such as the database
+	// being unavailable, is retried until it is stored. Backoff doubles from
+	// RetryBackoff up to RetryMaxBackoff between attempts.
+	RetryBackoff    time.Duration `envconfig:"KAFKA_RETRY_BACKOFF" default:"200ms"`
+	RetryMaxBackoff time.Duration `envconfig:"KAFKA_RETRY_MAX_BACKOFF" default:"10s"`
+}
+
+// Retention expires raw visited events older than Days whole UTC days.
+// Rollups are never expired. Days of 0 keeps raw events forever.
+type Retention struct {
+	Days int `envconfig:"RETENTION_DAYS" default:"0"`
+	// Mode is "delete" to remove expired events or "anonymize" to keep them
+	// without personal data.
+	Mode     string        `envconfig:"RETENTION_MODE" default:"delete"`
+	Interval time.Duration `envconfig:"RETENTION_INTERVAL" default:"1h"`
+	// ArchiveDir, when set, receives one file per expired day, written
+	// before the events are changed. The files are written from Postgres;
+	// ClickHouse holds the same visits and only expires them.
+	ArchiveDir    string `envconfig:"RETENTION_ARCHIVE_DIR"`
+	ArchiveFormat string `envconfig:"RETENTION_ARCHIVE_FORMAT" default:"ndjson"`
+}
+
+// Redis holds the HyperLogLog sketches of unique visitors. Unique visitor
+// counts are disabled when Addr is empty.
+type Redis struct {
+	Addr     string `envconfig:"REDIS_ADDRESS"`
+	Password string `envconfig:"REDIS_PASSWORD"`
+	DB       int    `envconfig:"REDIS_DB"`
+}
+
+// Uniques sets how long the per-bucket unique visitor sketches are kept. The
+// all-time sketch of a link never expires.
+type Uniques struct {
+	HourlyTTL time.Duration `envconfig:"UNIQUES_HOURLY_TTL" default:"768h"`
+	DailyTTL  time.Duration `envconfig:"UNIQUES_DAILY_TTL" default:"9600h"`
+}
+
+// Live configures the live visit streams.
+type Live struct {
+	// Buffer bounds the visits queued for a client that reads slower than
+	// they arrive. Visits beyond it are dropped for that client.
+	Buffer    int           `envconfig:"LIVE_BUFFER" default:"64"`
+	KeepAlive time.Duration `envconfig:"LIVE_KEEPALIVE" default:"15s"`
+}
+
+// Leaderboard sets how long a top or trending leaderboard is served from
+// memory before it is computed again.
+type Leaderboard struct {
+	CacheTTL time.Duration `envconfig:"LEADERBOARD_CACHE_TTL" default:"30s"`
+}
+
+type Tracing struct {
+	Exporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	Endpoint    string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	Insecure    bool    `envconfig:"TRACING_OTLP_INSECURE" default:"true"`
+	SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "analytics"
+
+var (
+	DuplicateEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "duplicates_dropped_total",
+		Help:      "Number of redelivered events dropped because their event ID was already stored, by event type.",
+	}, []string{"event_type"})
+
+	Retries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "retries_total",
+		Help:      "Number of retried attempts to handle a consumed message, by topic.",
+	}, []string{"topic"})
+
+	DeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dead_lettered_messages_total",
+		Help:      "Number of consumed messages sent to the dead-letter topic, by source topic.",
+	}, []string{"topic"})
+
+	UniqueVisitorErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "uniques",
+		Name:      "errors_total",
+		Help:      "Number of visits that could not be added to the unique visitor sketches.",
+	})
+
+	LiveSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "live",
+		Name:      "subscriptions",
+		Help:      "Number of open live visit streams.",
+	})
+
+	LiveDroppedVisits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "live",
+		Name:      "dropped_visits_total",
+		Help:      "Number of visits not sent to a live stream because its client fell behind.",
+	})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Stats summarises the events recorded for a single short url.
+type Stats struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	TotalVisits int64      `json:"total_visits"`
+	// UniqueVisitors is an estimate, left out when unique counting is
+	// disabled.
+	UniqueVisitors int64       `json:"unique_visitors,omitempty"`
+	LastVisitAt    *time.Time  `json:"last_visit_at,omitempty"`
+	Countries      []Breakdown `json:"countries"`
+	Referrers      []Breakdown `json:"referrers"`
+	Browsers       []Breakdown `json:"browsers"`
+	Devices        []Breakdown `json:"devices"`
+	// Sources breaks visits down by traffic source, and Campaigns by
+	// utm_campaign. Visits recorded before sources were classified have an
+	// empty source unless they were direct.
+	Sources   []Breakdown `json:"sources"`
+	Campaigns []Breakdown `json:"campaigns"`
+}
+
+// Breakdown is the number of visits sharing one value of a dimension. An
+// empty referrer is a direct visit.
+type Breakdown struct {
+	Value string `json:"value" db:"value"`
+	Count int64  `json:"count" db:"count"`
+}
+
+// SeriesPoint is the number of visits in the hour or day starting at Bucket.
+type SeriesPoint struct {
+	Bucket         time.Time `json:"bucket" db:"bucket"`
+	Visits         int64     `json:"visits" db:"visits"`
+	UniqueVisitors int64     `json:"unique_visitors,omitempty" db:"-"`
+}
+
+// TopLink is a link of the top or trending leaderboard. Baseline and Score
+// are only set for trending links: Baseline is the average visits per window
+// before the current one, and Score how far Visits exceeds it.
+type TopLink struct {
+	ShortURL string  `json:"short_url" db:"short_url"`
+	Visits   int64   `json:"visits" db:"visits"`
+	Baseline float64 `json:"baseline,omitempty" db:"baseline"`
+	Score    float64 `json:"score,omitempty" db:"score"`
+}
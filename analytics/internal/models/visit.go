@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// VisitRecord is a raw row of url_visited_events, as archived and exported.
+// Columns the database leaves NULL, such as the IP of an anonymized visit,
+// are empty.
+type VisitRecord struct {
+	EventID      string    `json:"event_id" db:"event_id" parquet:"event_id"`
+	ShortURL     string    `json:"short_url" db:"short_url" parquet:"short_url"`
+	EventTime    time.Time `json:"event_time" db:"event_time" parquet:"event_time"`
+	UserID       string    `json:"user_id,omitempty" db:"user_id" parquet:"user_id,optional"`
+	Referrer     string    `json:"referrer,omitempty" db:"referer" parquet:"referrer,optional"`
+	IPAddress    string    `json:"ip_address,omitempty" db:"ip_address" parquet:"ip_address,optional"`
+	UserAgent    string    `json:"user_agent,omitempty" db:"user_agent" parquet:"user_agent,optional"`
+	Country      string    `json:"country,omitempty" db:"country" parquet:"country,optional"`
+	Region       string    `json:"region,omitempty" db:"region" parquet:"region,optional"`
+	City         string    `json:"city,omitempty" db:"city" parquet:"city,optional"`
+	Browser      string    `json:"browser,omitempty" db:"browser" parquet:"browser,optional"`
+	OS           string    `json:"os,omitempty" db:"os" parquet:"os,optional"`
+	DeviceType   string    `json:"device_type,omitempty" db:"device_type" parquet:"device_type,optional"`
+	VisitorID    string    `json:"visitor_id,omitempty" db:"visitor_id" parquet:"visitor_id,optional"`
+	IsBot        bool      `json:"is_bot" db:"is_bot" parquet:"is_bot"`
+	ReferrerHost string    `json:"referrer_host,omitempty" db:"referrer_host" parquet:"referrer_host,optional"`
+	ReferrerPath string    `json:"referrer_path,omitempty" db:"referrer_path" parquet:"referrer_path,optional"`
+	Source       string    `json:"source,omitempty" db:"source" parquet:"source,optional"`
+	UTMSource    string    `json:"utm_source,omitempty" db:"utm_source" parquet:"utm_source,optional"`
+	UTMMedium    string    `json:"utm_medium,omitempty" db:"utm_medium" parquet:"utm_medium,optional"`
+	UTMCampaign  string    `json:"utm_campaign,omitempty" db:"utm_campaign" parquet:"utm_campaign,optional"`
+}
@@ -3,34 +3,56 @@ package clickhouse
 import (
 	"analytics/internal/config"
 	"context"
-	"database/sql"
+	"events"
 	"fmt"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type repository struct {
-	db *sql.DB
+var tracer = otel.Tracer("analytics/internal/repository/clickhouse")
+
+// Repository stores raw visits in ClickHouse. Unlike Postgres it keeps no
+// rollups or links; the stats are still served from Postgres.
+type Repository struct {
+	db *sqlx.DB
+}
+
+func New(ctx context.Context, cfg *config.Config) (*Repository, error) {
+	const op = "repository.clickhouse.New"
+
+	db := open(cfg)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Repository{db: db}, nil
 }
 
-func New(ctx context.Context, cfg *config.Config) (*repository, error) {
-	addr := fmt.Sprintf("%s:%s", cfg.DB.Host, cfg.DB.Port)
+func open(cfg *config.Config) *sqlx.DB {
 	conn := clickhouse.OpenDB(&clickhouse.Options{
-		Addr: []string{addr},
+		Addr: []string{cfg.ClickHouse.Addr},
 		Auth: clickhouse.Auth{
-			Database: cfg.DB.Name,
-			Username: cfg.DB.Username,
-			Password: cfg.DB.Username,
+			Database: cfg.ClickHouse.Name,
+			Username: cfg.ClickHouse.Username,
+			Password: cfg.ClickHouse.Password,
 		},
 		Settings: clickhouse.Settings{
 			"max_execution_time": 60,
+			// Retention reports the rows it expired, so its mutations
+			// finish before the statements return.
+			"mutations_sync": 1,
 		},
 		DialTimeout: time.Second * 30,
 		Compression: &clickhouse.Compression{
 			Method: clickhouse.CompressionLZ4,
 		},
-		Debug:                true,
 		BlockBufferSize:      10,
 		MaxCompressionBuffer: 10240,
 	})
@@ -38,13 +60,86 @@ func New(ctx context.Context, cfg *config.Config) (*repository, error) {
 	conn.SetMaxOpenConns(10)
 	conn.SetConnMaxLifetime(time.Hour)
 
-	if err := conn.Ping(); err != nil {
-		return nil, err
+	return sqlx.NewDb(conn, "clickhouse")
+}
+
+func (r *Repository) Close() {
+	r.db.Close()
+}
+
+// SaveVisitedEvent stores the visit of event. ClickHouse has no unique
+// constraint to report a redelivery with; the table collapses rows sharing
+// an event ID when its parts merge. Until then the exports and retention
+// counts read with FINAL, while the unique visitor estimates need not: a
+// redelivered visit has the same visitor, which uniq counts once.
+func (r *Repository) SaveVisitedEvent(ctx context.Context, event events.UrlEvent) error {
+	const op = "repository.clickhouse.SaveVisitedEvent"
+
+	query := `INSERT INTO url_visited_events (
+			event_id, short_url, event_time, user_id, referer, ip_address, user_agent,
+			country, region, city, browser, os, device_type, visitor_id, is_bot,
+			referrer_host, referrer_path, source, utm_source, utm_medium, utm_campaign
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	v := event.Visit
+	_, err := r.exec(ctx, "SaveVisitedEvent", query,
+		event.EventID, event.ShortURL, event.EventTime, nullable(event.UserID), v.Referrer, nullable(v.IPAddress), nullable(v.UserAgent),
+		v.Country, v.Region, v.City, v.Browser, v.OS, v.DeviceType, nullable(v.VisitorID), v.IsBot,
+		v.ReferrerHost, nullable(v.ReferrerPath), v.Source, v.UTMSource, v.UTMMedium, v.UTMCampaign,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &repository{db: conn}, nil
+	return nil
 }
 
-func (r *repository) Close() {
-	r.db.Close()
+// nullable stores an empty s as NULL, like Postgres does for the columns
+// anonymization clears.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (r *Repository) exec(ctx context.Context, name, query string, args ...any) (int64, error) {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return 0, err
+	}
+
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+func (r *Repository) get(ctx context.Context, name, query string, dest any, args ...any) error {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	if err := r.db.GetContext(ctx, dest, query, args...); err != nil {
+		recordError(span, err)
+		return err
+	}
+
+	return nil
+}
+
+func startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "clickhouse."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemClickhouse,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
 }
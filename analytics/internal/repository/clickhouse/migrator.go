@@ -0,0 +1,39 @@
+package clickhouse
+
+import (
+	"analytics/internal/config"
+	migrations "analytics/migrations/clickhouse"
+	"fmt"
+	"log/slog"
+	"platform/schema"
+
+	"github.com/golang-migrate/migrate/v4"
+	migrateclickhouse "github.com/golang-migrate/migrate/v4/database/clickhouse"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// NewMigrator returns a migrator for the embedded ClickHouse migrations.
+func NewMigrator(cfg *config.Config, l *slog.Logger) (*schema.Migrator, error) {
+	const op = "repository.clickhouse.NewMigrator"
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The driver closes the database on Close, so it gets its own pool.
+	db := open(cfg)
+	driver, err := migrateclickhouse.WithInstance(db.DB, &migrateclickhouse.Config{DatabaseName: cfg.ClickHouse.Name})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "clickhouse", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schema.New(m, l), nil
+}
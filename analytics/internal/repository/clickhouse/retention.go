@@ -0,0 +1,179 @@
+package clickhouse
+
+import (
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrOwnerFilter is returned for visit filters on the link owner, since
+// ClickHouse does not store the links.
+var ErrOwnerFilter = errors.New("clickhouse does not store link owners")
+
+// identifiable matches visits that still hold personal data.
+const identifiable = `(ip_address IS NOT NULL OR user_agent IS NOT NULL OR user_id IS NOT NULL OR visitor_id IS NOT NULL)`
+
+func visitWhere(f repository.VisitFilter) (string, []any, error) {
+	if f.Owner != "" {
+		return "", nil, ErrOwnerFilter
+	}
+
+	var (
+		conds []string
+		args  []any
+	)
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conds = append(conds, "event_time >= ?")
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conds = append(conds, "event_time < ?")
+	}
+	if f.Identifiable {
+		conds = append(conds, identifiable)
+	}
+	if f.ShortURL != "" {
+		args = append(args, f.ShortURL)
+		conds = append(conds, "short_url = ?")
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// OldestVisit returns the time of the oldest visit matching f, or
+// repository.ErrNotFound when there is none.
+func (r *Repository) OldestVisit(ctx context.Context, f repository.VisitFilter) (time.Time, error) {
+	const op = "repository.clickhouse.OldestVisit"
+
+	where, args, err := visitWhere(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var oldest struct {
+		Time  time.Time `db:"oldest"`
+		Count uint64    `db:"visits"`
+	}
+	query := "SELECT min(event_time) AS oldest, count() AS visits FROM url_visited_events" + where
+	if err := r.get(ctx, "OldestVisit", query, &oldest, args...); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// min of no rows is the zero of the column type rather than NULL.
+	if oldest.Count == 0 {
+		return time.Time{}, repository.ErrNotFound
+	}
+
+	return oldest.Time.UTC(), nil
+}
+
+// VisitRecords calls fn for every visit matching f, oldest first, while
+// reading them from the database. Redelivered visits that were not merged
+// yet are returned once.
+func (r *Repository) VisitRecords(ctx context.Context, f repository.VisitFilter, fn func(models.VisitRecord) error) error {
+	const op = "repository.clickhouse.VisitRecords"
+
+	where, args, err := visitWhere(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	query := `SELECT event_id, short_url, event_time,
+			ifNull(user_id, '') AS user_id, referer,
+			ifNull(ip_address, '') AS ip_address, ifNull(user_agent, '') AS user_agent,
+			country, region, city, browser, os, device_type,
+			ifNull(visitor_id, '') AS visitor_id, is_bot,
+			referrer_host, ifNull(referrer_path, '') AS referrer_path,
+			source, utm_source, utm_medium, utm_campaign
+		FROM url_visited_events FINAL` + where + ` ORDER BY event_time, event_id`
+
+	ctx, span := startSpan(ctx, "VisitRecords", query)
+	defer span.End()
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec models.VisitRecord
+		if err := rows.StructScan(&rec); err != nil {
+			recordError(span, err)
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		rec.EventTime = rec.EventTime.UTC()
+		if err := fn(rec); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteVisits removes the raw visits matching f with a mutation, which
+// rewrites the affected parts. Mutations report no row count, so the visits
+// are counted first.
+func (r *Repository) DeleteVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	const op = "repository.clickhouse.DeleteVisits"
+
+	n, err := r.mutate(ctx, "DeleteVisits", f, "ALTER TABLE url_visited_events DELETE")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// AnonymizeVisits drops the personal data of the visits matching f, as the
+// Postgres backend does: the IP address, user agent, user ID and visitor ID,
+// and the path and query of the referer.
+func (r *Repository) AnonymizeVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	const op = "repository.clickhouse.AnonymizeVisits"
+
+	f.Identifiable = true
+	n, err := r.mutate(ctx, "AnonymizeVisits", f, `ALTER TABLE url_visited_events UPDATE
+			ip_address = NULL, user_agent = NULL, user_id = NULL, visitor_id = NULL,
+			referer = extract(referer, '^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]+'), referrer_path = NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// mutate counts the visits matching f and runs the ALTER TABLE mutation on
+// them. A mutation needs a WHERE clause, so an empty f matches everything.
+func (r *Repository) mutate(ctx context.Context, name string, f repository.VisitFilter, alter string) (int64, error) {
+	where, args, err := visitWhere(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	if err := r.get(ctx, name, "SELECT count() FROM url_visited_events FINAL"+where, &n, args...); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if where == "" {
+		where = " WHERE 1"
+	}
+	if _, err := r.exec(ctx, name, alter+where, args...); err != nil {
+		return 0, err
+	}
+
+	return int64(n), nil
+}
@@ -0,0 +1,114 @@
+package clickhouse
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"context"
+	urlevents "events"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository migrates the database at TEST_CLICKHOUSE_ADDR up for the
+// test and back down after it.
+func newTestRepository(t *testing.T) *Repository {
+	addr := os.Getenv("TEST_CLICKHOUSE_ADDR")
+	if addr == "" {
+		t.Skip("set TEST_CLICKHOUSE_ADDR to run against clickhouse")
+	}
+
+	cfg := &config.Config{ClickHouse: config.ClickHouse{Addr: addr, Name: "default", Username: "default"}}
+	m, err := NewMigrator(cfg, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	require.NoError(t, m.Up())
+	t.Cleanup(func() {
+		require.NoError(t, m.Down(0))
+		m.Close()
+	})
+
+	r, err := New(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func TestVisitWhere(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args, err := visitWhere(repository.VisitFilter{From: from, To: from.AddDate(0, 0, 1), Identifiable: true, ShortURL: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, " WHERE event_time >= ? AND event_time < ? AND "+identifiable+" AND short_url = ?", where)
+	assert.Equal(t, []any{from, from.AddDate(0, 0, 1), "abc"}, args)
+
+	where, args, err = visitWhere(repository.VisitFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	_, _, err = visitWhere(repository.VisitFilter{Owner: "alice"})
+	assert.ErrorIs(t, err, ErrOwnerFilter)
+}
+
+func TestRetention(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, at := range []time.Time{day.Add(time.Hour), day.Add(2 * time.Hour), day.AddDate(0, 0, 1)} {
+		e := urlevents.New(urlevents.KindVisited, "abc", at)
+		e.Visit = urlevents.Visit{
+			IPAddress: "203.0.113.10", UserAgent: "Mozilla/5.0", Country: "Germany",
+			Referrer: "https://www.google.com/search?q=tiny", ReferrerHost: "www.google.com", ReferrerPath: "/search",
+		}
+		require.NoError(t, r.SaveVisitedEvent(ctx, e))
+		if i == 0 {
+			// A redelivery is archived and expired once.
+			require.NoError(t, r.SaveVisitedEvent(ctx, e))
+		}
+	}
+
+	oldest, err := r.OldestVisit(ctx, repository.VisitFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, day.Add(time.Hour), oldest)
+
+	first := repository.VisitFilter{From: day, To: day.AddDate(0, 0, 1)}
+	var archived []models.VisitRecord
+	require.NoError(t, r.VisitRecords(ctx, first, func(rec models.VisitRecord) error {
+		archived = append(archived, rec)
+		return nil
+	}))
+	require.Len(t, archived, 2)
+	assert.Equal(t, "203.0.113.10", archived[0].IPAddress)
+	assert.Equal(t, "/search", archived[0].ReferrerPath)
+
+	n, err := r.AnonymizeVisits(ctx, first)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	first.Identifiable = true
+	_, err = r.OldestVisit(ctx, first)
+	assert.ErrorIs(t, err, repository.ErrNotFound, "nothing left to anonymize")
+
+	require.NoError(t, r.VisitRecords(ctx, repository.VisitFilter{From: day, To: day.Add(time.Hour + time.Second)}, func(rec models.VisitRecord) error {
+		assert.Empty(t, rec.IPAddress)
+		assert.Empty(t, rec.UserAgent)
+		assert.Equal(t, "https://www.google.com", rec.Referrer)
+		assert.Equal(t, "Germany", rec.Country)
+		return nil
+	}))
+
+	n, err = r.DeleteVisits(ctx, repository.VisitFilter{To: day.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	oldest, err = r.OldestVisit(ctx, repository.VisitFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, day.AddDate(0, 0, 1), oldest)
+}
@@ -0,0 +1,75 @@
+package clickhouse
+
+import (
+	"analytics/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// visitor identifies the visitor of a row like the consumer keys the Redis
+// sketches: the daily visitor ID, or else the IP address and user agent.
+// Rows with neither, such as anonymized visits, are NULL and left out by
+// uniq.
+const visitor = `if(visitor_id IS NOT NULL, visitor_id,
+	if(ip_address IS NULL AND user_agent IS NULL, NULL, concat(ifNull(ip_address, ''), '\0', ifNull(user_agent, ''))))`
+
+// UniqueVisitors estimates the distinct visitors of shortURL with uniq, an
+// adaptive sketch like HyperLogLog, over the visits ClickHouse still holds.
+// Visits anonymized or deleted by retention are no longer counted.
+func (r *Repository) UniqueVisitors(ctx context.Context, shortURL string, includeBots bool) (int64, error) {
+	const op = "repository.clickhouse.UniqueVisitors"
+
+	query := `SELECT uniq(` + visitor + `) FROM url_visited_events
+		WHERE short_url = ? AND (NOT is_bot OR ?)`
+
+	var n uint64
+	if err := r.get(ctx, "UniqueVisitors", query, &n, shortURL, includeBots); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int64(n), nil
+}
+
+// UniqueVisitorsSeries estimates the distinct visitors of shortURL in each of
+// buckets, which are hours or days in ascending order.
+func (r *Repository) UniqueVisitorsSeries(ctx context.Context, shortURL, granularity string, buckets []time.Time, includeBots bool) ([]int64, error) {
+	const op = "repository.clickhouse.UniqueVisitorsSeries"
+
+	counts := make([]int64, len(buckets))
+	if len(buckets) == 0 {
+		return counts, nil
+	}
+
+	trunc, step := "toStartOfDay", 24*time.Hour
+	if granularity == repository.GranularityHour {
+		trunc, step = "toStartOfHour", time.Hour
+	}
+	query := `SELECT ` + trunc + `(event_time, 'UTC') AS bucket, uniq(` + visitor + `) AS visitors
+		FROM url_visited_events
+		WHERE short_url = ? AND event_time >= ? AND event_time < ? AND (NOT is_bot OR ?)
+		GROUP BY bucket`
+
+	var rows []struct {
+		Bucket   time.Time `db:"bucket"`
+		Visitors uint64    `db:"visitors"`
+	}
+	ctx, span := startSpan(ctx, "UniqueVisitorsSeries", query)
+	defer span.End()
+
+	err := r.db.SelectContext(ctx, &rows, query, shortURL, buckets[0], buckets[len(buckets)-1].Add(step), includeBots)
+	if err != nil {
+		recordError(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byBucket := make(map[time.Time]int64, len(rows))
+	for _, row := range rows {
+		byBucket[row.Bucket.UTC()] = int64(row.Visitors)
+	}
+	for i, bucket := range buckets {
+		counts[i] = byBucket[bucket.UTC()]
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,55 @@
+package clickhouse
+
+import (
+	"analytics/internal/repository"
+	"context"
+	urlevents "events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniques(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+
+	hour := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	visits := []struct {
+		at  time.Time
+		bot bool
+		v   urlevents.Visit
+	}{
+		{hour, false, urlevents.Visit{VisitorID: "alice"}},
+		{hour.Add(10 * time.Minute), false, urlevents.Visit{VisitorID: "alice"}},
+		{hour.Add(20 * time.Minute), false, urlevents.Visit{IPAddress: "203.0.113.10", UserAgent: "Mozilla/5.0"}},
+		{hour.Add(30 * time.Minute), true, urlevents.Visit{VisitorID: "crawler"}},
+		// Opted out of tracking: not counted.
+		{hour.Add(40 * time.Minute), false, urlevents.Visit{}},
+		{hour.Add(2 * time.Hour), false, urlevents.Visit{VisitorID: "alice"}},
+	}
+	for _, v := range visits {
+		e := urlevents.New(urlevents.KindVisited, "abc", v.at)
+		e.Visit = v.v
+		e.Visit.IsBot = v.bot
+		require.NoError(t, r.SaveVisitedEvent(ctx, e))
+	}
+
+	n, err := r.UniqueVisitors(ctx, "abc", false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	n, err = r.UniqueVisitors(ctx, "abc", true)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	buckets := []time.Time{hour, hour.Add(time.Hour), hour.Add(2 * time.Hour)}
+	series, err := r.UniqueVisitorsSeries(ctx, "abc", repository.GranularityHour, buckets, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 0, 1}, series)
+
+	series, err = r.UniqueVisitorsSeries(ctx, "abc", repository.GranularityDay, []time.Time{hour.Truncate(24 * time.Hour)}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, series)
+}
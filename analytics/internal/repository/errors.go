@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("short url not found")
+	// ErrDuplicateEvent is returned when an event with the same ID was
+	// already stored, as happens on Kafka redelivery. Nothing is written.
+	ErrDuplicateEvent = errors.New("event already stored")
+	// ErrInvalidData is returned when the database rejects the values of an
+	// event, so storing it again cannot succeed.
+	ErrInvalidData = errors.New("invalid event data")
+)
+
+// Granularities of the visit rollups.
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// Bucket returns the start of the UTC hour or day containing t.
+func Bucket(t time.Time, granularity string) time.Time {
+	if granularity == GranularityHour {
+		return t.UTC().Truncate(time.Hour)
+	}
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// BreakdownLimit is the number of values kept per stats dimension.
+const BreakdownLimit = 10
+
+// VisitFilter selects raw visited events with From <= event_time < To. A zero
+// bound is open. Identifiable limits the selection to events that still hold
+// personal data, that is were not anonymized yet. ShortURL and Owner, when
+// set, limit it to one link or to the links created by one user.
+type VisitFilter struct {
+	From         time.Time
+	To           time.Time
+	Identifiable bool
+	ShortURL     string
+	Owner        string
+}
+
+// The trending leaderboard compares the visits of a window with the average
+// of the BaselineWindows windows before it. Score adds TrendingPrior visits to
+// both, so links with few visits need a larger jump to rank high.
+const (
+	BaselineWindows = 4
+	TrendingPrior   = 5
+)
+
+// TrendingScore returns the score of a link with visits in the current
+// window and baseline visits per window before it.
+func TrendingScore(visits int64, baseline float64) float64 {
+	return (float64(visits) + TrendingPrior) / (baseline + TrendingPrior)
+}
@@ -0,0 +1,384 @@
+// Package memory keeps url events in process memory. It is meant for tests
+// and local development; nothing survives a restart.
+package memory
+
+import (
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"cmp"
+	"context"
+	"events"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Repository struct {
+	mu      sync.RWMutex
+	created []events.UrlEvent
+	visited []events.UrlEvent
+	deleted []events.UrlEvent
+	// seen holds the stored event IDs, like the unique event_id constraint
+	// of the SQL backends.
+	seen map[string]struct{}
+}
+
+func New() *Repository {
+	return &Repository{seen: make(map[string]struct{})}
+}
+
+func (r *Repository) Close() {}
+
+func (r *Repository) SaveCreatedEvent(ctx context.Context, event events.UrlEvent) error {
+	return r.save(&r.created, event)
+}
+
+func (r *Repository) SaveVisitedEvent(ctx context.Context, event events.UrlEvent) error {
+	return r.save(&r.visited, event)
+}
+
+// SaveVisitedEvents stores batch and returns how many events were new.
+func (r *Repository) SaveVisitedEvents(ctx context.Context, batch []events.UrlEvent) (int64, error) {
+	var n int64
+	for _, event := range batch {
+		if err := r.save(&r.visited, event); err == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *Repository) SaveDeletedEvent(ctx context.Context, event events.UrlEvent) error {
+	return r.save(&r.deleted, event)
+}
+
+func (r *Repository) save(dst *[]events.UrlEvent, event events.UrlEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.seen[event.EventID]; ok {
+		return repository.ErrDuplicateEvent
+	}
+	r.seen[event.EventID] = struct{}{}
+
+	*dst = append(*dst, event)
+	return nil
+}
+
+func (r *Repository) Stats(ctx context.Context, shortURL string, includeBots bool) (*models.Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &models.Stats{ShortURL: shortURL}
+	found := false
+
+	for _, e := range r.created {
+		if e.ShortURL != shortURL {
+			continue
+		}
+		if stats.CreatedAt == nil || e.EventTime.Before(*stats.CreatedAt) {
+			stats.OriginalURL = e.OriginalURL
+			stats.CreatedAt = &e.EventTime
+		}
+		found = true
+	}
+
+	countries := map[string]int64{}
+	referrers := map[string]int64{}
+	browsers := map[string]int64{}
+	devices := map[string]int64{}
+	sources := map[string]int64{}
+	campaigns := map[string]int64{}
+
+	for _, e := range r.visited {
+		if e.ShortURL != shortURL || e.Visit.IsBot && !includeBots {
+			continue
+		}
+		stats.TotalVisits++
+		if stats.LastVisitAt == nil || e.EventTime.After(*stats.LastVisitAt) {
+			stats.LastVisitAt = &e.EventTime
+		}
+		countries[e.Visit.Country]++
+		referrers[e.Visit.Referrer]++
+		browsers[e.Visit.Browser]++
+		devices[e.Visit.DeviceType]++
+		sources[e.Visit.Source]++
+		campaigns[e.Visit.UTMCampaign]++
+		found = true
+	}
+
+	if !found {
+		return nil, repository.ErrNotFound
+	}
+
+	stats.Countries = breakdown(countries)
+	stats.Referrers = breakdown(referrers)
+	stats.Browsers = breakdown(browsers)
+	stats.Devices = breakdown(devices)
+	stats.Sources = breakdown(sources)
+	stats.Campaigns = breakdown(campaigns)
+
+	return stats, nil
+}
+
+func (r *Repository) Owner(ctx context.Context, shortURL string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *events.UrlEvent
+	for i, e := range r.created {
+		if e.ShortURL == shortURL && (latest == nil || !e.EventTime.Before(latest.EventTime)) {
+			latest = &r.created[i]
+		}
+	}
+	if latest == nil {
+		return "", repository.ErrNotFound
+	}
+
+	return latest.UserID, nil
+}
+
+// Series counts visits per bucket from the raw events; the memory backend
+// keeps no rollups.
+func (r *Repository) Series(ctx context.Context, shortURL, granularity string, from, to time.Time, includeBots bool) ([]models.SeriesPoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[time.Time]int64{}
+	for _, e := range r.visited {
+		bucket := repository.Bucket(e.EventTime, granularity)
+		if e.ShortURL != shortURL || bucket.Before(from) || !bucket.Before(to) || e.Visit.IsBot && !includeBots {
+			continue
+		}
+		counts[bucket]++
+	}
+
+	points := make([]models.SeriesPoint, 0, len(counts))
+	for bucket, visits := range counts {
+		points = append(points, models.SeriesPoint{Bucket: bucket, Visits: visits})
+	}
+	slices.SortFunc(points, func(a, b models.SeriesPoint) int {
+		return a.Bucket.Compare(b.Bucket)
+	})
+
+	return points, nil
+}
+
+// Top counts the human visits per link between from and to from the raw
+// events.
+func (r *Repository) Top(ctx context.Context, from, to time.Time, limit int) ([]models.TopLink, error) {
+	links := r.trending(from, from, to, 1)
+	slices.SortFunc(links, func(a, b models.TopLink) int {
+		if c := cmp.Compare(b.Visits, a.Visits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ShortURL, b.ShortURL)
+	})
+	for i := range links {
+		links[i].Baseline, links[i].Score = 0, 0
+	}
+
+	return links[:min(limit, len(links))], nil
+}
+
+func (r *Repository) Trending(ctx context.Context, baselineFrom, from, to time.Time, limit int) ([]models.TopLink, error) {
+	links := r.trending(baselineFrom, from, to, repository.BaselineWindows)
+	slices.SortFunc(links, func(a, b models.TopLink) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.Visits, a.Visits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ShortURL, b.ShortURL)
+	})
+
+	return links[:min(limit, len(links))], nil
+}
+
+// trending counts the human visits per link between from and to and its
+// baseline between baselineFrom and from, bucketed by hour like the rollups.
+// Links without visits between from and to are left out.
+func (r *Repository) trending(baselineFrom, from, to time.Time, windows int) []models.TopLink {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	visits := map[string]int64{}
+	baseline := map[string]int64{}
+	for _, e := range r.visited {
+		bucket := repository.Bucket(e.EventTime, repository.GranularityHour)
+		switch {
+		case e.Visit.IsBot || bucket.Before(baselineFrom) || !bucket.Before(to):
+		case bucket.Before(from):
+			baseline[e.ShortURL]++
+		default:
+			visits[e.ShortURL]++
+		}
+	}
+
+	links := make([]models.TopLink, 0, len(visits))
+	for shortURL, n := range visits {
+		avg := float64(baseline[shortURL]) / float64(windows)
+		links = append(links, models.TopLink{
+			ShortURL: shortURL,
+			Visits:   n,
+			Baseline: avg,
+			Score:    repository.TrendingScore(n, avg),
+		})
+	}
+
+	return links
+}
+
+// breakdown orders counts the same way the SQL backends do: most visits
+// first, ties broken by value.
+func breakdown(counts map[string]int64) []models.Breakdown {
+	out := make([]models.Breakdown, 0, len(counts))
+	for value, count := range counts {
+		out = append(out, models.Breakdown{Value: value, Count: count})
+	}
+
+	slices.SortFunc(out, func(a, b models.Breakdown) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Value, b.Value)
+	})
+
+	if len(out) > repository.BreakdownLimit {
+		out = out[:repository.BreakdownLimit]
+	}
+
+	return out
+}
+
+// match reports whether e is selected by f. r.mu must be held.
+func (r *Repository) match(e events.UrlEvent, f repository.VisitFilter) bool {
+	switch {
+	case !f.From.IsZero() && e.EventTime.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.EventTime.Before(f.To):
+		return false
+	case f.Identifiable && e.Visit.IPAddress == "" && e.Visit.UserAgent == "" && e.UserID == "" && e.Visit.VisitorID == "":
+		return false
+	case f.ShortURL != "" && e.ShortURL != f.ShortURL:
+		return false
+	case f.Owner != "":
+		return slices.ContainsFunc(r.created, func(c events.UrlEvent) bool {
+			return c.ShortURL == e.ShortURL && c.UserID == f.Owner && !e.EventTime.Before(c.EventTime) &&
+				!r.deletedBetween(e.ShortURL, c.EventTime, e.EventTime)
+		})
+	}
+	return true
+}
+
+// deletedBetween reports whether shortURL was deleted after from and no later
+// than to. r.mu must be held.
+func (r *Repository) deletedBetween(shortURL string, from, to time.Time) bool {
+	return slices.ContainsFunc(r.deleted, func(d events.UrlEvent) bool {
+		return d.ShortURL == shortURL && d.EventTime.After(from) && !d.EventTime.After(to)
+	})
+}
+
+func (r *Repository) OldestVisit(ctx context.Context, f repository.VisitFilter) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var oldest time.Time
+	for _, e := range r.visited {
+		if r.match(e, f) && (oldest.IsZero() || e.EventTime.Before(oldest)) {
+			oldest = e.EventTime
+		}
+	}
+	if oldest.IsZero() {
+		return time.Time{}, repository.ErrNotFound
+	}
+
+	return oldest, nil
+}
+
+func (r *Repository) VisitRecords(ctx context.Context, f repository.VisitFilter, fn func(models.VisitRecord) error) error {
+	r.mu.RLock()
+	var records []models.VisitRecord
+	for _, e := range r.visited {
+		if r.match(e, f) {
+			records = append(records, models.VisitRecord{
+				EventID:    e.EventID,
+				ShortURL:   e.ShortURL,
+				EventTime:  e.EventTime,
+				UserID:     e.UserID,
+				Referrer:   e.Visit.Referrer,
+				IPAddress:  e.Visit.IPAddress,
+				UserAgent:  e.Visit.UserAgent,
+				Country:    e.Visit.Country,
+				Region:     e.Visit.Region,
+				City:       e.Visit.City,
+				Browser:    e.Visit.Browser,
+				OS:         e.Visit.OS,
+				DeviceType: e.Visit.DeviceType,
+				VisitorID:  e.Visit.VisitorID,
+				IsBot:      e.Visit.IsBot,
+
+				ReferrerHost: e.Visit.ReferrerHost,
+				ReferrerPath: e.Visit.ReferrerPath,
+				Source:       e.Visit.Source,
+				UTMSource:    e.Visit.UTMSource,
+				UTMMedium:    e.Visit.UTMMedium,
+				UTMCampaign:  e.Visit.UTMCampaign,
+			})
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(records, func(a, b models.VisitRecord) int {
+		if c := a.EventTime.Compare(b.EventTime); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.EventID, b.EventID)
+	})
+
+	for _, rec := range records {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.visited)
+	r.visited = slices.DeleteFunc(r.visited, func(e events.UrlEvent) bool { return r.match(e, f) })
+
+	return int64(before - len(r.visited)), nil
+}
+
+func (r *Repository) AnonymizeVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f.Identifiable = true
+	var n int64
+	for i, e := range r.visited {
+		if !r.match(e, f) {
+			continue
+		}
+		r.visited[i].UserID = ""
+		r.visited[i].Visit.IPAddress = ""
+		r.visited[i].Visit.UserAgent = ""
+		r.visited[i].Visit.VisitorID = ""
+		r.visited[i].Visit.ReferrerPath = ""
+		if u, err := url.Parse(e.Visit.Referrer); err == nil && u.Scheme != "" && u.Host != "" {
+			r.visited[i].Visit.Referrer = u.Scheme + "://" + u.Host
+		} else {
+			r.visited[i].Visit.Referrer = ""
+		}
+		n++
+	}
+
+	return n, nil
+}
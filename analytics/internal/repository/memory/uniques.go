@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"analytics/internal/repository"
+	"context"
+	"sync"
+	"time"
+)
+
+// Uniques counts unique visitors exactly, standing in for the Redis sketches
+// in tests and local development.
+type Uniques struct {
+	mu     sync.RWMutex
+	visits []uniqueVisit
+}
+
+type uniqueVisit struct {
+	shortURL string
+	at       time.Time
+	bot      bool
+	visitor  string
+}
+
+func NewUniques() *Uniques {
+	return &Uniques{}
+}
+
+func (u *Uniques) AddVisitor(ctx context.Context, shortURL string, at time.Time, bot bool, visitor string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.visits = append(u.visits, uniqueVisit{shortURL: shortURL, at: at, bot: bot, visitor: visitor})
+	return nil
+}
+
+func (u *Uniques) UniqueVisitors(ctx context.Context, shortURL string, includeBots bool) (int64, error) {
+	return u.count(shortURL, includeBots, func(time.Time) bool { return true }), nil
+}
+
+func (u *Uniques) UniqueVisitorsSeries(ctx context.Context, shortURL, granularity string, buckets []time.Time, includeBots bool) ([]int64, error) {
+	counts := make([]int64, len(buckets))
+	for i, bucket := range buckets {
+		counts[i] = u.count(shortURL, includeBots, func(at time.Time) bool {
+			return repository.Bucket(at, granularity).Equal(bucket)
+		})
+	}
+	return counts, nil
+}
+
+func (u *Uniques) count(shortURL string, includeBots bool, match func(time.Time) bool) int64 {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	seen := map[string]struct{}{}
+	for _, v := range u.visits {
+		if v.shortURL == shortURL && (!v.bot || includeBots) && match(v.at) {
+			seen[v.visitor] = struct{}{}
+		}
+	}
+	return int64(len(seen))
+}
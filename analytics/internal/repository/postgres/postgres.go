@@ -2,13 +2,27 @@ package postgres
 
 import (
 	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository"
 	"context"
+	"database/sql"
+	"errors"
+	"events"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("analytics/internal/repository/postgres")
+
 type Repository struct {
 	db *sqlx.DB
 }
@@ -16,7 +30,16 @@ type Repository struct {
 func New(ctx context.Context, cfg *config.Config) (*Repository, error) {
 	const op = "repository.postgres.New"
 
-	dns := fmt.Sprintf(
+	db, err := sqlx.ConnectContext(ctx, "pgx", DSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Repository{db}, nil
+}
+
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
 		cfg.DB.Username,
 		cfg.DB.Password,
@@ -24,14 +47,297 @@ func New(ctx context.Context, cfg *config.Config) (*Repository, error) {
 		cfg.DB.Port,
 		cfg.DB.Name,
 	)
-	db, err := sqlx.ConnectContext(ctx, "pgx", dns)
-	if err != nil {
+}
+
+func (r *Repository) Close() {
+	r.db.Close()
+}
+
+func (r *Repository) SaveCreatedEvent(ctx context.Context, event events.UrlEvent) error {
+	const op = "repository.postgres.SaveCreatedEvent"
+
+	query := `INSERT INTO url_created_events (event_id, short_url, original_url, user_id, event_time)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (event_id) DO NOTHING`
+
+	return r.insert(ctx, op, "SaveCreatedEvent", query,
+		event.EventID, event.ShortURL, event.OriginalURL, event.UserID, event.EventTime,
+	)
+}
+
+// SaveVisitedEvent stores event and adds it to the rollups in the same
+// statement, so a visit is counted in both or in neither.
+func (r *Repository) SaveVisitedEvent(ctx context.Context, event events.UrlEvent) error {
+	const op = "repository.postgres.SaveVisitedEvent"
+
+	// A duplicate inserts no event and therefore no rollup rows, which is
+	// what insert reports as repository.ErrDuplicateEvent.
+	query := `WITH ` + visitedInsert(1) + `
+		INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, is_bot, visits)
+		` + rollupSelect("inserted") + `
+		ON CONFLICT (short_url, granularity, dimension, bucket, value, is_bot)
+		DO UPDATE SET visits = url_visit_rollups.visits + EXCLUDED.visits`
+
+	return r.insert(ctx, op, "SaveVisitedEvent", query, visitedArgs(nil, event)...)
+}
+
+// MaxVisitBatch bounds the events of one SaveVisitedEvents call, keeping its
+// statement under the Postgres limit of 65535 parameters.
+const MaxVisitBatch = 1000
+
+// SaveVisitedEvents stores up to MaxVisitBatch visits and their rollups in
+// one statement and returns how many were new. Events stored before are
+// skipped, like the duplicates of SaveVisitedEvent.
+func (r *Repository) SaveVisitedEvents(ctx context.Context, batch []events.UrlEvent) (int64, error) {
+	const op = "repository.postgres.SaveVisitedEvents"
+
+	if len(batch) > MaxVisitBatch {
+		return 0, fmt.Errorf("%s: batch of %d events exceeds %d", op, len(batch), MaxVisitBatch)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	query := `WITH ` + visitedInsert(len(batch)) + `, rollups AS (
+			INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, is_bot, visits)
+			` + rollupSelect("inserted") + `
+			ON CONFLICT (short_url, granularity, dimension, bucket, value, is_bot)
+			DO UPDATE SET visits = url_visit_rollups.visits + EXCLUDED.visits
+		)
+		SELECT count(*) FROM inserted`
+
+	args := make([]any, 0, len(batch)*visitedColumns)
+	for _, event := range batch {
+		args = visitedArgs(args, event)
+	}
+
+	var n int64
+	if err := r.get(ctx, "SaveVisitedEvents", query, &n, args...); err != nil {
+		if invalidData(err) {
+			return 0, fmt.Errorf("%s: %w: %w", op, repository.ErrInvalidData, err)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// visitedColumns is the number of values visitedArgs appends per event.
+const visitedColumns = 21
+
+// visitedInsert is the "inserted" CTE storing rows visits, each given as
+// visitedColumns parameters in the order of visitedArgs. It returns the
+// columns rollupSelect reads.
+func visitedInsert(rows int) string {
+	values := make([]string, rows)
+	for i := range values {
+		p := func(n int) int { return i*visitedColumns + n }
+		values[i] = fmt.Sprintf(`($%d, $%d, $%d, $%d, $%d, NULLIF($%d, '')::inet, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d,
+				$%d, $%d, $%d, $%d, $%d, $%d)`,
+			p(1), p(2), p(3), p(4), p(5), p(6), p(7), p(8), p(9), p(10), p(11), p(12), p(13), p(14), p(15),
+			p(16), p(17), p(18), p(19), p(20), p(21))
+	}
+
+	return `inserted AS (
+			INSERT INTO url_visited_events (
+				event_id, short_url, event_time, user_id, referer, ip_address, user_agent,
+				country, region, city, browser, os, device_type, visitor_id, is_bot,
+				referrer_host, referrer_path, source, utm_source, utm_medium, utm_campaign
+			) VALUES ` + strings.Join(values, ",\n\t\t\t\t") + `
+			ON CONFLICT (event_id) DO NOTHING
+			RETURNING short_url, event_time, country, device_type, browser, os, referer, source, utm_campaign, is_bot
+		)`
+}
+
+func visitedArgs(args []any, event events.UrlEvent) []any {
+	return append(args,
+		event.EventID, event.ShortURL, event.EventTime, event.UserID, event.Visit.Referrer, event.Visit.IPAddress, event.Visit.UserAgent,
+		event.Visit.Country, event.Visit.Region, event.Visit.City, event.Visit.Browser, event.Visit.OS, event.Visit.DeviceType,
+		event.Visit.VisitorID, event.Visit.IsBot,
+		event.Visit.ReferrerHost, event.Visit.ReferrerPath, event.Visit.Source,
+		event.Visit.UTMSource, event.Visit.UTMMedium, event.Visit.UTMCampaign,
+	)
+}
+
+func (r *Repository) SaveDeletedEvent(ctx context.Context, event events.UrlEvent) error {
+	const op = "repository.postgres.SaveDeletedEvent"
+
+	query := `INSERT INTO url_deleted_events (event_id, short_url, user_id, event_time)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (event_id) DO NOTHING`
+
+	return r.insert(ctx, op, "SaveDeletedEvent", query,
+		event.EventID, event.ShortURL, event.UserID, event.EventTime,
+	)
+}
+
+// Stats aggregates the visits of shortURL. Bots are only counted when
+// includeBots is set.
+func (r *Repository) Stats(ctx context.Context, shortURL string, includeBots bool) (*models.Stats, error) {
+	const op = "repository.postgres.Stats"
+
+	stats := &models.Stats{ShortURL: shortURL}
+
+	createdQuery := `SELECT original_url, event_time FROM url_created_events
+		WHERE short_url = $1 ORDER BY event_time LIMIT 1`
+
+	var created struct {
+		OriginalURL sql.NullString `db:"original_url"`
+		EventTime   time.Time      `db:"event_time"`
+	}
+	err := r.get(ctx, "Stats", createdQuery, &created, shortURL)
+	switch {
+	case err == nil:
+		stats.OriginalURL = created.OriginalURL.String
+		stats.CreatedAt = &created.EventTime
+	case !errors.Is(err, sql.ErrNoRows):
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Repository{db}, nil
+	// Totals and most breakdowns come from the daily rollups. Referrers are
+	// reported as full urls, which the rollups reduce to hosts, so they are
+	// still counted from the raw events.
+	totalQuery := `SELECT COALESCE(sum(visits), 0)::bigint FROM url_visit_rollups
+		WHERE short_url = $1 AND granularity = 'day' AND dimension = '' AND (NOT is_bot OR $2)`
+	if err := r.get(ctx, "Stats", totalQuery, &stats.TotalVisits, shortURL, includeBots); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stats.CreatedAt == nil && stats.TotalVisits == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	lastQuery := `SELECT max(event_time) FROM url_visited_events WHERE short_url = $1 AND (NOT is_bot OR $2)`
+
+	var last sql.NullTime
+	if err := r.get(ctx, "Stats", lastQuery, &last, shortURL, includeBots); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if last.Valid {
+		stats.LastVisitAt = &last.Time
+	}
+
+	rollupQuery := fmt.Sprintf(`SELECT value, sum(visits)::bigint AS count FROM url_visit_rollups
+		WHERE short_url = $1 AND granularity = 'day' AND dimension = $2 AND (NOT is_bot OR $3)
+		GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT %d`, repository.BreakdownLimit)
+	rawQuery := fmt.Sprintf(`SELECT COALESCE(referer, '') AS value, count(*) AS count
+		FROM url_visited_events WHERE short_url = $1 AND (NOT is_bot OR $2)
+		GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT %d`, repository.BreakdownLimit)
+
+	dimensions := []struct {
+		query  string
+		args   []any
+		target *[]models.Breakdown
+	}{
+		{rollupQuery, []any{shortURL, "country", includeBots}, &stats.Countries},
+		{rawQuery, []any{shortURL, includeBots}, &stats.Referrers},
+		{rollupQuery, []any{shortURL, "browser", includeBots}, &stats.Browsers},
+		{rollupQuery, []any{shortURL, "device_type", includeBots}, &stats.Devices},
+		{rollupQuery, []any{shortURL, "source", includeBots}, &stats.Sources},
+		{rollupQuery, []any{shortURL, "utm_campaign", includeBots}, &stats.Campaigns},
+	}
+	for _, d := range dimensions {
+		*d.target = []models.Breakdown{}
+		if err := r.selectAll(ctx, "Stats", d.query, d.target, d.args...); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return stats, nil
 }
 
-func (r *Repository) Close() {
-	r.db.Close()
+// Owner returns the user who created shortURL, empty for links created
+// anonymously. An alias can be reused after it was deleted, so the latest
+// creation wins.
+func (r *Repository) Owner(ctx context.Context, shortURL string) (string, error) {
+	const op = "repository.postgres.Owner"
+
+	query := `SELECT COALESCE(user_id, '') FROM url_created_events
+		WHERE short_url = $1 ORDER BY event_time DESC LIMIT 1`
+
+	var owner string
+	if err := r.get(ctx, "Owner", query, &owner, shortURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", repository.ErrNotFound
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return owner, nil
+}
+
+func (r *Repository) get(ctx context.Context, name, query string, dest any, args ...any) error {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	if err := r.db.GetContext(ctx, dest, query, args...); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			recordError(span, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) selectAll(ctx context.Context, name, query string, dest any, args ...any) error {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	if err := r.db.SelectContext(ctx, dest, query, args...); err != nil {
+		recordError(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// insert runs an INSERT ... ON CONFLICT DO NOTHING and reports
+// repository.ErrDuplicateEvent when the row already existed.
+func (r *Repository) insert(ctx context.Context, op, name, query string, args ...any) error {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		if invalidData(err) {
+			return fmt.Errorf("%s: %w: %w", op, repository.ErrInvalidData, err)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrDuplicateEvent)
+	}
+
+	return nil
+}
+
+// invalidData reports whether err is a data exception (class 22) or an
+// integrity violation (class 23), which depend on the values and not on the
+// state of the database.
+func invalidData(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+func startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "postgres."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
 }
@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// identifiable matches visits that still hold personal data.
+const identifiable = `(ip_address IS NOT NULL OR user_agent IS NOT NULL OR user_id IS NOT NULL OR visitor_id IS NOT NULL)`
+
+func visitWhere(f repository.VisitFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if !f.From.IsZero() {
+		args = append(args, f.From)
+		conds = append(conds, fmt.Sprintf("event_time >= $%d", len(args)))
+	}
+	if !f.To.IsZero() {
+		args = append(args, f.To)
+		conds = append(conds, fmt.Sprintf("event_time < $%d", len(args)))
+	}
+	if f.Identifiable {
+		conds = append(conds, identifiable)
+	}
+	if f.ShortURL != "" {
+		args = append(args, f.ShortURL)
+		conds = append(conds, fmt.Sprintf("short_url = $%d", len(args)))
+	}
+	if f.Owner != "" {
+		// An alias can be deleted and created again by another user, so only
+		// the visits between a creation by the owner and the next deletion
+		// of the alias are theirs.
+		args = append(args, f.Owner)
+		conds = append(conds, fmt.Sprintf(`EXISTS (SELECT 1 FROM url_created_events c
+			WHERE c.short_url = url_visited_events.short_url AND c.user_id = $%d
+				AND c.event_time <= url_visited_events.event_time
+				AND NOT EXISTS (SELECT 1 FROM url_deleted_events d
+					WHERE d.short_url = c.short_url AND d.event_time > c.event_time
+						AND d.event_time <= url_visited_events.event_time))`, len(args)))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// OldestVisit returns the time of the oldest visit matching f, or
+// repository.ErrNotFound when there is none.
+func (r *Repository) OldestVisit(ctx context.Context, f repository.VisitFilter) (time.Time, error) {
+	const op = "repository.postgres.OldestVisit"
+
+	where, args := visitWhere(f)
+
+	var oldest sql.NullTime
+	if err := r.get(ctx, "OldestVisit", "SELECT min(event_time) FROM url_visited_events"+where, &oldest, args...); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !oldest.Valid {
+		return time.Time{}, repository.ErrNotFound
+	}
+
+	return oldest.Time, nil
+}
+
+// VisitRecords calls fn for every visit matching f, oldest first, while
+// reading them from the database.
+func (r *Repository) VisitRecords(ctx context.Context, f repository.VisitFilter, fn func(models.VisitRecord) error) error {
+	const op = "repository.postgres.VisitRecords"
+
+	where, args := visitWhere(f)
+	query := `SELECT event_id, short_url, event_time,
+			COALESCE(user_id, '') AS user_id, COALESCE(referer, '') AS referer,
+			COALESCE(host(ip_address), '') AS ip_address, COALESCE(user_agent, '') AS user_agent,
+			COALESCE(country, '') AS country, COALESCE(region, '') AS region, COALESCE(city, '') AS city,
+			COALESCE(browser, '') AS browser, COALESCE(os, '') AS os, COALESCE(device_type, '') AS device_type,
+			COALESCE(visitor_id, '') AS visitor_id, is_bot,
+			COALESCE(referrer_host, '') AS referrer_host, COALESCE(referrer_path, '') AS referrer_path,
+			COALESCE(source, '') AS source, COALESCE(utm_source, '') AS utm_source,
+			COALESCE(utm_medium, '') AS utm_medium, COALESCE(utm_campaign, '') AS utm_campaign
+		FROM url_visited_events` + where + ` ORDER BY event_time, event_id`
+
+	ctx, span := startSpan(ctx, "VisitRecords", query)
+	defer span.End()
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec models.VisitRecord
+		if err := rows.StructScan(&rec); err != nil {
+			recordError(span, err)
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(rec); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteVisits removes the raw visits matching f. Rollups are kept.
+func (r *Repository) DeleteVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	const op = "repository.postgres.DeleteVisits"
+
+	where, args := visitWhere(f)
+
+	return r.update(ctx, op, "DeleteVisits", "DELETE FROM url_visited_events"+where, args...)
+}
+
+// AnonymizeVisits drops the personal data of the visits matching f: the IP
+// address, user agent, user ID and visitor ID, and the path and query of the
+// referer.
+// Everything the rollups are built from is kept, so a backfill still yields
+// the same numbers.
+func (r *Repository) AnonymizeVisits(ctx context.Context, f repository.VisitFilter) (int64, error) {
+	const op = "repository.postgres.AnonymizeVisits"
+
+	f.Identifiable = true
+	where, args := visitWhere(f)
+	query := `UPDATE url_visited_events SET
+			ip_address = NULL, user_agent = NULL, user_id = NULL, visitor_id = NULL,
+			referer = substring(referer FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]+'), referrer_path = NULL` + where
+
+	return r.update(ctx, op, "AnonymizeVisits", query, args...)
+}
+
+func (r *Repository) update(ctx context.Context, op, name, query string, args ...any) (int64, error) {
+	ctx, span := startSpan(ctx, name, query)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
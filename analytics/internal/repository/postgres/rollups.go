@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// rollupSelect aggregates visits read from source into url_visit_rollups
+// rows: one total and one per dimension value, for each granularity, with
+// bots and humans counted apart. source needs the short_url, event_time,
+// country, device_type, browser, os, referer, source, utm_campaign and
+// is_bot columns of url_visited_events. Migrations 000006 and 000008 use
+// the same expression to rebuild the table.
+func rollupSelect(source string) string {
+	return fmt.Sprintf(`SELECT g.granularity, date_trunc(g.granularity, e.event_time, 'UTC'), e.short_url,
+			d.dimension, d.value, e.is_bot, count(*)
+		FROM %s e
+		CROSS JOIN (VALUES ('hour'), ('day')) AS g(granularity)
+		CROSS JOIN LATERAL (VALUES
+			('', ''),
+			('country', COALESCE(e.country, '')),
+			('device_type', COALESCE(e.device_type, '')),
+			('browser', COALESCE(e.browser, '')),
+			('os', COALESCE(e.os, '')),
+			('referer_host', referer_host(e.referer)),
+			('source', COALESCE(e.source, '')),
+			('utm_campaign', COALESCE(e.utm_campaign, ''))
+		) AS d(dimension, value)
+		WHERE e.short_url IS NOT NULL AND e.event_time IS NOT NULL
+		GROUP BY 1, 2, 3, 4, 5, 6`, source)
+}
+
+// Backfill rebuilds the rollups of [from, to) from url_visited_events and
+// returns the number of rollup rows written. Both bounds must be at midnight
+// UTC, so no daily bucket is rebuilt from part of its events. The rebuild
+// runs in one transaction; stats read the old rollups until it commits.
+func (r *Repository) Backfill(ctx context.Context, from, to time.Time) (int64, error) {
+	const op = "repository.postgres.Backfill"
+
+	if !from.Equal(from.Truncate(24*time.Hour)) || !to.Equal(to.Truncate(24*time.Hour)) || !from.Before(to) {
+		return 0, fmt.Errorf("%s: range %s - %s is not whole UTC days", op, from, to)
+	}
+
+	ctx, span := startSpan(ctx, "Backfill", "rebuild url_visit_rollups")
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	deleteQuery := `DELETE FROM url_visit_rollups WHERE bucket >= $1 AND bucket < $2`
+	if _, err := tx.ExecContext(ctx, deleteQuery, from, to); err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insertQuery := `INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, is_bot, visits)
+		` + rollupSelect(`(SELECT * FROM url_visited_events WHERE event_time >= $1 AND event_time < $2)`)
+	res, err := tx.ExecContext(ctx, insertQuery, from, to)
+	if err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		recordError(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// Top returns the links with the most human visits between from and to,
+// counted from the hourly rollups.
+func (r *Repository) Top(ctx context.Context, from, to time.Time, limit int) ([]models.TopLink, error) {
+	const op = "repository.postgres.Top"
+
+	query := `SELECT short_url, sum(visits)::bigint AS visits FROM url_visit_rollups
+		WHERE granularity = 'hour' AND dimension = '' AND NOT is_bot AND bucket >= $1 AND bucket < $2
+		GROUP BY short_url ORDER BY visits DESC, short_url LIMIT $3`
+
+	links := []models.TopLink{}
+	if err := r.selectAll(ctx, "Top", query, &links, from, to, limit); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// Trending returns the links visited between from and to ranked by
+// repository.TrendingScore, the baseline being counted between baselineFrom
+// and from. Like Top it reads the hourly rollups and leaves bots out.
+func (r *Repository) Trending(ctx context.Context, baselineFrom, from, to time.Time, limit int) ([]models.TopLink, error) {
+	const op = "repository.postgres.Trending"
+
+	// Keep the score in line with repository.TrendingScore.
+	query := `SELECT short_url, visits, baseline, (visits + $5::float8) / (baseline + $5::float8) AS score FROM (
+			SELECT short_url,
+				COALESCE(sum(visits) FILTER (WHERE bucket >= $2), 0)::bigint AS visits,
+				COALESCE(sum(visits) FILTER (WHERE bucket < $2), 0)::float8 / $4::float8 AS baseline
+			FROM url_visit_rollups
+			WHERE granularity = 'hour' AND dimension = '' AND NOT is_bot AND bucket >= $1 AND bucket < $3
+			GROUP BY short_url
+		) AS windows
+		WHERE visits > 0
+		ORDER BY score DESC, visits DESC, short_url LIMIT $6`
+
+	links := []models.TopLink{}
+	err := r.selectAll(ctx, "Trending", query, &links,
+		baselineFrom, from, to, float64(repository.BaselineWindows), float64(repository.TrendingPrior), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+func (r *Repository) Series(ctx context.Context, shortURL, granularity string, from, to time.Time, includeBots bool) ([]models.SeriesPoint, error) {
+	const op = "repository.postgres.Series"
+
+	query := `SELECT bucket, sum(visits)::bigint AS visits FROM url_visit_rollups
+		WHERE short_url = $1 AND granularity = $2 AND dimension = '' AND bucket >= $3 AND bucket < $4
+			AND (NOT is_bot OR $5)
+		GROUP BY bucket ORDER BY bucket`
+
+	points := []models.SeriesPoint{}
+	if err := r.selectAll(ctx, "Series", query, &points, shortURL, granularity, from, to, includeBots); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
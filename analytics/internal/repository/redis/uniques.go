@@ -0,0 +1,131 @@
+// Package redis estimates unique visitors with Redis HyperLogLog sketches.
+// A sketch stays around 12 KB however many visitors it counts, at a standard
+// error of 0.81%.
+package redis
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// Uniques keeps one sketch per link for all time and one per hourly and
+// daily bucket, for humans and bots apart. Counting with bots merges both.
+type Uniques struct {
+	client *redis.Client
+	ttl    map[string]time.Duration
+}
+
+func New(cfg *config.Config) (*Uniques, error) {
+	const op = "repository.redis.New"
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Uniques{
+		client: client,
+		ttl: map[string]time.Duration{
+			repository.GranularityHour: cfg.Uniques.HourlyTTL,
+			repository.GranularityDay:  cfg.Uniques.DailyTTL,
+		},
+	}, nil
+}
+
+func (u *Uniques) Close() error {
+	return u.client.Close()
+}
+
+// AddVisitor counts visitor on shortURL at the given time. Adding the same
+// visitor again changes nothing, so redelivered events are harmless.
+func (u *Uniques) AddVisitor(ctx context.Context, shortURL string, at time.Time, bot bool, visitor string) error {
+	const op = "repository.redis.AddVisitor"
+
+	_, err := u.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.PFAdd(ctx, key(shortURL, bot), visitor)
+		for granularity, ttl := range u.ttl {
+			bucket := repository.Bucket(at, granularity)
+			k := bucketKey(shortURL, bot, granularity, bucket)
+			p.PFAdd(ctx, k, visitor)
+			// Expiring relative to the bucket keeps late events from
+			// extending it.
+			p.ExpireAt(ctx, k, bucket.Add(ttl))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UniqueVisitors estimates the distinct visitors of shortURL since it was
+// first visited.
+func (u *Uniques) UniqueVisitors(ctx context.Context, shortURL string, includeBots bool) (int64, error) {
+	const op = "repository.redis.UniqueVisitors"
+
+	keys := []string{key(shortURL, false)}
+	if includeBots {
+		keys = append(keys, key(shortURL, true))
+	}
+
+	n, err := u.client.PFCount(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// UniqueVisitorsSeries estimates the distinct visitors of shortURL in each of
+// buckets. Expired buckets count 0.
+func (u *Uniques) UniqueVisitorsSeries(ctx context.Context, shortURL, granularity string, buckets []time.Time, includeBots bool) ([]int64, error) {
+	const op = "repository.redis.UniqueVisitorsSeries"
+
+	cmds := make([]*redis.IntCmd, len(buckets))
+	_, err := u.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, bucket := range buckets {
+			keys := []string{bucketKey(shortURL, false, granularity, bucket)}
+			if includeBots {
+				keys = append(keys, bucketKey(shortURL, true, granularity, bucket))
+			}
+			cmds[i] = p.PFCount(ctx, keys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	counts := make([]int64, len(buckets))
+	for i, cmd := range cmds {
+		counts[i] = cmd.Val()
+	}
+
+	return counts, nil
+}
+
+func key(shortURL string, bot bool) string {
+	kind := "human"
+	if bot {
+		kind = "bot"
+	}
+	return "uniques:" + shortURL + ":" + kind
+}
+
+func bucketKey(shortURL string, bot bool, granularity string, bucket time.Time) string {
+	return key(shortURL, bot) + ":" + granularity + ":" + bucket.UTC().Format("2006-01-02T15")
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniques(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDRESS")
+	if addr == "" {
+		t.Skip("set TEST_REDIS_ADDRESS to run against redis")
+	}
+
+	u, err := New(&config.Config{
+		Redis:   config.Redis{Addr: addr, DB: 15},
+		Uniques: config.Uniques{HourlyTTL: 24 * time.Hour, DailyTTL: 24 * time.Hour},
+	})
+	require.NoError(t, err)
+	require.NoError(t, u.client.FlushDB(context.Background()).Err())
+	t.Cleanup(func() { u.Close() })
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Hour)
+	visits := []struct {
+		at      time.Time
+		bot     bool
+		visitor string
+	}{
+		{now, false, "alice"},
+		{now.Add(10 * time.Minute), false, "alice"},
+		{now.Add(20 * time.Minute), false, "bob"},
+		{now.Add(30 * time.Minute), true, "crawler"},
+		{now.Add(-time.Hour), false, "alice"},
+	}
+	for _, v := range visits {
+		require.NoError(t, u.AddVisitor(ctx, "abc", v.at, v.bot, v.visitor))
+	}
+
+	n, err := u.UniqueVisitors(ctx, "abc", false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	n, err = u.UniqueVisitors(ctx, "abc", true)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	counts, err := u.UniqueVisitorsSeries(ctx, "abc", repository.GranularityHour, []time.Time{now.Add(-time.Hour), now, now.Add(time.Hour)}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 3, 0}, counts)
+
+	ttl, err := u.client.TTL(ctx, bucketKey("abc", false, repository.GranularityHour, now)).Result()
+	require.NoError(t, err)
+	// Expiry counts from the start of the bucket, not from the last write.
+	assert.WithinDuration(t, now.Add(24*time.Hour), time.Now().Add(ttl), time.Minute)
+
+	n, err = u.UniqueVisitors(ctx, "missing", true)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
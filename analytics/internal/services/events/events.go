@@ -0,0 +1,124 @@
+package events
+
+import (
+	"analytics/internal/metrics"
+	"analytics/internal/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"events"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type Repository interface {
+	SaveCreatedEvent(ctx context.Context, event events.UrlEvent) error
+	SaveVisitedEvent(ctx context.Context, event events.UrlEvent) error
+	SaveDeletedEvent(ctx context.Context, event events.UrlEvent) error
+}
+
+// UniqueCounter feeds the unique visitor estimates.
+type UniqueCounter interface {
+	AddVisitor(ctx context.Context, shortURL string, at time.Time, bot bool, visitor string) error
+}
+
+// Publisher passes stored events on to the live streams.
+type Publisher interface {
+	Publish(event events.UrlEvent)
+}
+
+type Service struct {
+	logger     *slog.Logger
+	repository Repository
+	uniques    UniqueCounter
+	publisher  Publisher
+}
+
+// New returns a service storing events in r. Visitors are also counted in u
+// and stored events published to p, unless they are nil.
+func New(l *slog.Logger, r Repository, u UniqueCounter, p Publisher) *Service {
+	return &Service{
+		logger:     l,
+		repository: r,
+		uniques:    u,
+		publisher:  p,
+	}
+}
+
+// Handle stores a single url event according to its type. Events whose ID
+// was already stored are dropped, so redeliveries are not counted twice.
+func (s *Service) Handle(ctx context.Context, event events.UrlEvent) error {
+	const op = "services.events.Handle"
+
+	var err error
+	switch event.Kind {
+	case events.KindCreated:
+		err = s.repository.SaveCreatedEvent(ctx, event)
+	case events.KindVisited:
+		err = s.repository.SaveVisitedEvent(ctx, event)
+	case events.KindDeleted:
+		err = s.repository.SaveDeletedEvent(ctx, event)
+	default:
+		err = fmt.Errorf("%w: %q", events.ErrUnknownKind, event.Kind)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrDuplicateEvent):
+		metrics.DuplicateEvents.WithLabelValues(string(event.Kind)).Inc()
+		s.logger.DebugContext(ctx, "duplicate event dropped", "type", event.Kind, "event_id", event.EventID)
+		return nil
+	case errors.Is(err, repository.ErrInvalidData):
+		// Retrying cannot help; the consumer dead-letters invalid events.
+		return fmt.Errorf("%s: %w: %w", op, events.ErrInvalidEvent, err)
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.logger.DebugContext(ctx, "event stored", "type", event.Kind, "short_url", event.ShortURL)
+
+	if event.Kind == events.KindVisited {
+		s.countVisitor(ctx, event)
+	}
+	if s.publisher != nil {
+		s.publisher.Publish(event)
+	}
+
+	return nil
+}
+
+// countVisitor adds the visitor of event to the unique counts. The counts
+// are an estimate anyway, so a failure is logged rather than holding up the
+// stored event.
+func (s *Service) countVisitor(ctx context.Context, event events.UrlEvent) {
+	if s.uniques == nil {
+		return
+	}
+
+	visitor := visitorKey(event.Visit)
+	if visitor == "" {
+		return
+	}
+
+	if err := s.uniques.AddVisitor(ctx, event.ShortURL, event.EventTime, event.Visit.IsBot, visitor); err != nil {
+		metrics.UniqueVisitorErrors.Inc()
+		s.logger.WarnContext(ctx, "failed to count unique visitor", "short_url", event.ShortURL, "error", err)
+	}
+}
+
+// visitorKey identifies the visitor of v: the daily visitor ID when the
+// shortener sent one, otherwise a hash of the IP address and user agent, so
+// the raw values never reach the counters. Visits without either, as sent
+// for clients that opted out of tracking, have no key and are not counted.
+func visitorKey(v events.Visit) string {
+	if v.VisitorID != "" {
+		return v.VisitorID
+	}
+	if v.IPAddress == "" && v.UserAgent == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(v.IPAddress + "\x00" + v.UserAgent))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,100 @@
+// Package export streams raw visits to the data team as CSV, NDJSON or
+// Parquet.
+package export
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrInvalidExport = errors.New("invalid export request")
+
+type Repository interface {
+	VisitRecords(ctx context.Context, f repository.VisitFilter, fn func(models.VisitRecord) error) error
+}
+
+// Query selects the visits of a link or of all links of an owner with
+// From <= event_time < To. A zero bound is open.
+type Query struct {
+	ShortURL string
+	Owner    string
+	From     time.Time
+	To       time.Time
+	Format   string
+	// Columns limits the output to these columns, in this order. All
+	// columns are written when empty.
+	Columns []string
+	Gzip    bool
+}
+
+// Ext returns the file extension of the output of q.
+func (q Query) Ext() string {
+	if q.Gzip {
+		return "." + q.Format + ".gz"
+	}
+	return "." + q.Format
+}
+
+type Service struct {
+	repository Repository
+}
+
+func New(r Repository) *Service {
+	return &Service{repository: r}
+}
+
+// Export writes the visits selected by q to w, oldest first, as they are
+// read from the database, and returns their number. Nothing is written when
+// q is invalid.
+func (s *Service) Export(ctx context.Context, w io.Writer, q Query) (int64, error) {
+	const op = "services.export.Export"
+
+	switch {
+	case q.ShortURL == "" && q.Owner == "":
+		return 0, fmt.Errorf("%s: %w: a short url or an owner is required", op, ErrInvalidExport)
+	case !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To):
+		return 0, fmt.Errorf("%s: %w: from must be before to", op, ErrInvalidExport)
+	}
+
+	// gzip writes nothing before the first record or Close, so an invalid
+	// format or column is still reported before any output.
+	var gz *gzip.Writer
+	if q.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	out, err := archive.NewWriter(q.Format, w, q.Columns...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w: %w", op, ErrInvalidExport, err)
+	}
+
+	f := repository.VisitFilter{ShortURL: q.ShortURL, Owner: q.Owner, From: q.From, To: q.To}
+
+	var count int64
+	err = s.repository.VisitRecords(ctx, f, func(rec models.VisitRecord) error {
+		count++
+		return out.Write(rec)
+	})
+	if err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return count, fmt.Errorf("%s: %w", op, err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return count, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return count, nil
+}
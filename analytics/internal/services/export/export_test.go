@@ -0,0 +1,192 @@
+package export
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/repository/memory"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"events"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepo(t *testing.T) *memory.Repository {
+	repo := memory.New()
+	ctx := context.Background()
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, link := range []struct{ alias, owner string }{{"abc", "alice"}, {"def", "alice"}, {"xyz", "bob"}} {
+		e := events.New(events.KindCreated, link.alias, day)
+		e.UserID = link.owner
+		require.NoError(t, repo.SaveCreatedEvent(ctx, e))
+
+		for i := range 2 {
+			v := events.New(events.KindVisited, link.alias, day.Add(time.Duration(i)*time.Hour))
+			v.Visit = events.Visit{IPAddress: "203.0.113.10", Country: "Germany", DeviceType: "Desktop"}
+			require.NoError(t, repo.SaveVisitedEvent(ctx, v))
+		}
+	}
+
+	return repo
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query Query
+		count int64
+		want  string
+	}{
+		{
+			name:  "csv columns of a link",
+			query: Query{ShortURL: "abc", Format: archive.FormatCSV, Columns: []string{"short_url", "event_time", "country", "is_bot"}},
+			count: 2,
+			want: "short_url,event_time,country,is_bot\n" +
+				"abc,2025-01-01T12:00:00Z,Germany,false\n" +
+				"abc,2025-01-01T13:00:00Z,Germany,false\n",
+		},
+		{
+			name:  "ndjson of an owner within a range",
+			query: Query{Owner: "alice", From: time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC), Format: archive.FormatNDJSON, Columns: []string{"short_url", "ip_address"}},
+			count: 2,
+			want: `{"short_url":"abc","ip_address":"203.0.113.10"}` + "\n" +
+				`{"short_url":"def","ip_address":"203.0.113.10"}` + "\n",
+		},
+		{
+			name:  "empty csv keeps the header",
+			query: Query{ShortURL: "abc", To: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Format: archive.FormatCSV, Columns: []string{"short_url"}},
+			want:  "short_url\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			n, err := New(newRepo(t)).Export(context.Background(), &buf, tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, n)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestExportReusedAlias(t *testing.T) {
+	t.Parallel()
+
+	// alice's link is deleted and bob creates one under the same alias.
+	repo := memory.New()
+	ctx := context.Background()
+	at := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, e := range []struct {
+		kind    events.Kind
+		owner   string
+		country string
+	}{
+		{events.KindCreated, "alice", ""},
+		{events.KindVisited, "", "France"},
+		{events.KindDeleted, "", ""},
+		{events.KindCreated, "bob", ""},
+		{events.KindVisited, "", "Spain"},
+	} {
+		ev := events.New(e.kind, "reused", at)
+		ev.UserID = e.owner
+		ev.Visit.Country = e.country
+		switch e.kind {
+		case events.KindCreated:
+			require.NoError(t, repo.SaveCreatedEvent(ctx, ev))
+		case events.KindVisited:
+			require.NoError(t, repo.SaveVisitedEvent(ctx, ev))
+		case events.KindDeleted:
+			require.NoError(t, repo.SaveDeletedEvent(ctx, ev))
+		}
+		at = at.Add(time.Hour)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"first owner", Query{Owner: "alice"}, "reused,France\n"},
+		{"second owner", Query{Owner: "bob"}, "reused,Spain\n"},
+		{"link of the second owner", Query{ShortURL: "reused", Owner: "bob"}, "reused,Spain\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Format = archive.FormatCSV
+			tt.query.Columns = []string{"short_url", "country"}
+
+			var buf bytes.Buffer
+			_, err := New(repo).Export(ctx, &buf, tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, "short_url,country\n"+tt.want, buf.String())
+		})
+	}
+}
+
+func TestExportParquetGzip(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	q := Query{ShortURL: "xyz", Format: archive.FormatParquet, Columns: []string{"event_id", "device_type"}, Gzip: true}
+	n, err := New(newRepo(t)).Export(context.Background(), &buf, q)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	assert.Equal(t, ".parquet.gz", q.Ext())
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	type row struct {
+		EventID    string `parquet:"event_id"`
+		DeviceType string `parquet:"device_type,optional"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "Desktop", rows[0].DeviceType)
+
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Len(t, file.Schema().Fields(), 2)
+}
+
+func TestExportInvalid(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{"no link or owner", Query{Format: archive.FormatCSV}},
+		{"empty range", Query{ShortURL: "abc", From: from, To: from, Format: archive.FormatCSV}},
+		{"unknown format", Query{ShortURL: "abc", Format: "xlsx", Gzip: true}},
+		{"unknown column", Query{ShortURL: "abc", Format: archive.FormatNDJSON, Columns: []string{"password"}}},
+		{"repeated column", Query{ShortURL: "abc", Format: archive.FormatCSV, Columns: []string{"country", "country"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			_, err := New(newRepo(t)).Export(context.Background(), &buf, tt.query)
+			assert.ErrorIs(t, err, ErrInvalidExport)
+			assert.Zero(t, buf.Len(), "nothing written")
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package live fans the consumed visits out to the clients following a link
+// as they arrive. Each instance only sees the partitions it consumes, so
+// clients of a scaled out deployment should be routed by link.
+package live
+
+import (
+	"analytics/internal/metrics"
+	"events"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Visit is what followers of a link receive for each visit.
+type Visit struct {
+	ShortURL   string    `json:"short_url"`
+	EventTime  time.Time `json:"event_time"`
+	Country    string    `json:"country,omitempty"`
+	DeviceType string    `json:"device_type,omitempty"`
+	Referrer   string    `json:"referrer,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	IsBot      bool      `json:"is_bot,omitempty"`
+}
+
+// Hub keeps the subscriptions by link. Publishing never blocks: a
+// subscription whose buffer is full misses visits instead of holding up the
+// consumer or the other subscriptions.
+type Hub struct {
+	buffer int
+
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub returns a hub buffering up to buffer visits per subscription.
+func NewHub(buffer int) *Hub {
+	return &Hub{
+		buffer: max(buffer, 1),
+		subs:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+type Subscription struct {
+	hub      *Hub
+	shortURL string
+	visits   chan Visit
+	dropped  atomic.Int64
+	once     sync.Once
+}
+
+// Subscribe follows the visits of shortURL until the subscription or the hub
+// is closed.
+func (h *Hub) Subscribe(shortURL string) *Subscription {
+	s := &Subscription{
+		hub:      h,
+		shortURL: shortURL,
+		visits:   make(chan Visit, h.buffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		s.once.Do(func() { close(s.visits) })
+		return s
+	}
+	if h.subs[shortURL] == nil {
+		h.subs[shortURL] = make(map[*Subscription]struct{})
+	}
+	h.subs[shortURL][s] = struct{}{}
+	metrics.LiveSubscriptions.Inc()
+
+	return s
+}
+
+// Publish hands a visited event to the subscriptions of its link. Other
+// kinds are ignored.
+func (h *Hub) Publish(event events.UrlEvent) {
+	if event.Kind != events.KindVisited {
+		return
+	}
+
+	v := Visit{
+		ShortURL:   event.ShortURL,
+		EventTime:  event.EventTime,
+		Country:    event.Visit.Country,
+		DeviceType: event.Visit.DeviceType,
+		Referrer:   event.Visit.Referrer,
+		Source:     event.Visit.Source,
+		IsBot:      event.Visit.IsBot,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs[event.ShortURL] {
+		select {
+		case s.visits <- v:
+		default:
+			s.dropped.Add(1)
+			metrics.LiveDroppedVisits.Inc()
+		}
+	}
+}
+
+// Close ends every subscription. Subscribing afterwards returns a
+// subscription that is already closed.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			s.once.Do(func() { close(s.visits) })
+			metrics.LiveSubscriptions.Dec()
+		}
+	}
+	clear(h.subs)
+}
+
+// Visits delivers the visits of the link. It is closed when the
+// subscription or the hub is.
+func (s *Subscription) Visits() <-chan Visit {
+	return s.visits
+}
+
+// Dropped returns the number of visits missed because the buffer was full
+// since the previous call.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *Subscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s.shortURL][s]; !ok {
+		return
+	}
+	delete(h.subs[s.shortURL], s)
+	if len(h.subs[s.shortURL]) == 0 {
+		delete(h.subs, s.shortURL)
+	}
+	s.once.Do(func() { close(s.visits) })
+	metrics.LiveSubscriptions.Dec()
+}
@@ -0,0 +1,56 @@
+package live
+
+import (
+	"events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func visit(shortURL string) events.UrlEvent {
+	e := events.New(events.KindVisited, shortURL, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	e.Visit = events.Visit{Country: "Germany"}
+	return e
+}
+
+func TestHub(t *testing.T) {
+	t.Parallel()
+
+	h := NewHub(2)
+	first, second, other := h.Subscribe("abc"), h.Subscribe("abc"), h.Subscribe("xyz")
+
+	h.Publish(visit("abc"))
+	h.Publish(events.New(events.KindCreated, "abc", time.Now()))
+
+	for _, s := range []*Subscription{first, second} {
+		v := <-s.Visits()
+		assert.Equal(t, "abc", v.ShortURL)
+		assert.Equal(t, "Germany", v.Country)
+	}
+	assert.Empty(t, other.Visits())
+
+	// second stops reading: visits beyond its buffer are dropped for it
+	// only.
+	for range 4 {
+		h.Publish(visit("abc"))
+		<-first.Visits()
+	}
+	assert.Len(t, second.Visits(), 2)
+	assert.EqualValues(t, 2, second.Dropped())
+	assert.EqualValues(t, 0, second.Dropped(), "reset once read")
+	assert.EqualValues(t, 0, first.Dropped())
+
+	first.Close()
+	_, open := <-first.Visits()
+	assert.False(t, open)
+	h.Publish(visit("abc"))
+
+	h.Close()
+	for range second.Visits() {
+	}
+	_, open = <-other.Visits()
+	assert.False(t, open)
+	_, open = <-h.Subscribe("abc").Visits()
+	assert.False(t, open, "subscribing to a closed hub")
+}
@@ -0,0 +1,220 @@
+package retention
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	ModeDelete    = "delete"
+	ModeAnonymize = "anonymize"
+)
+
+const day = 24 * time.Hour
+
+type Repository interface {
+	OldestVisit(ctx context.Context, f repository.VisitFilter) (time.Time, error)
+	VisitRecords(ctx context.Context, f repository.VisitFilter, fn func(models.VisitRecord) error) error
+	DeleteVisits(ctx context.Context, f repository.VisitFilter) (int64, error)
+	AnonymizeVisits(ctx context.Context, f repository.VisitFilter) (int64, error)
+}
+
+type Service struct {
+	cfg        config.Retention
+	logger     *slog.Logger
+	repository Repository
+}
+
+func New(cfg config.Retention, l *slog.Logger, r Repository) (*Service, error) {
+	const op = "services.retention.New"
+
+	switch {
+	case cfg.Days < 0:
+		return nil, fmt.Errorf("%s: RETENTION_DAYS must not be negative", op)
+	case cfg.Mode != ModeDelete && cfg.Mode != ModeAnonymize:
+		return nil, fmt.Errorf("%s: unknown RETENTION_MODE %q", op, cfg.Mode)
+	case cfg.ArchiveFormat != archive.FormatNDJSON && cfg.ArchiveFormat != archive.FormatParquet:
+		return nil, fmt.Errorf("%s: %w: %q", op, archive.ErrUnknownFormat, cfg.ArchiveFormat)
+	}
+
+	return &Service{
+		cfg:        cfg,
+		logger:     l,
+		repository: r,
+	}, nil
+}
+
+// Cutoff is the start of the oldest day whose events are kept at now when
+// events are retained for days.
+func Cutoff(days int, now time.Time) time.Time {
+	return now.UTC().Truncate(day).AddDate(0, 0, -days)
+}
+
+// Run expires the events before Cutoff, one day at a time, so each
+// archive file holds a single day and an interrupted run resumes with the
+// day it stopped at. It is a no-op when retention is disabled.
+func (s *Service) Run(ctx context.Context, now time.Time) error {
+	const op = "services.retention.Run"
+
+	if s.cfg.Days == 0 {
+		return nil
+	}
+
+	cutoff := Cutoff(s.cfg.Days, now)
+	pending := repository.VisitFilter{To: cutoff, Identifiable: s.cfg.Mode == ModeAnonymize}
+
+	oldest, err := s.repository.OldestVisit(ctx, pending)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for from := oldest.UTC().Truncate(day); from.Before(cutoff); from = from.Add(day) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.expireDay(ctx, from); err != nil {
+			return fmt.Errorf("%s: %s: %w", op, from.Format(time.DateOnly), err)
+		}
+	}
+
+	return nil
+}
+
+// Schedule calls Run right away and then every interval until ctx is done.
+// Failures are logged and retried at the next tick.
+func (s *Service) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Run(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.ErrorContext(ctx, "retention run failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) expireDay(ctx context.Context, from time.Time) error {
+	f := repository.VisitFilter{From: from, To: from.Add(day), Identifiable: s.cfg.Mode == ModeAnonymize}
+
+	if s.cfg.ArchiveDir != "" {
+		path, err := s.archive(ctx, f)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			s.logger.InfoContext(ctx, "raw events archived", "day", from.Format(time.DateOnly), "path", path)
+		}
+	}
+
+	var (
+		n   int64
+		err error
+	)
+	if s.cfg.Mode == ModeAnonymize {
+		n, err = s.repository.AnonymizeVisits(ctx, f)
+	} else {
+		n, err = s.repository.DeleteVisits(ctx, f)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "raw events expired", "day", from.Format(time.DateOnly), "mode", s.cfg.Mode, "events", n)
+
+	return nil
+}
+
+// archive writes the events selected by f to a file named after the day and
+// returns its path, or "" when there was nothing to write. The file appears
+// under its final name only once complete.
+func (s *Service) archive(ctx context.Context, f repository.VisitFilter) (string, error) {
+	ext := "." + s.cfg.ArchiveFormat
+	if s.cfg.ArchiveFormat == archive.FormatNDJSON {
+		ext += ".gz"
+	}
+	// Events that arrive late for an already archived day go to a second
+	// file rather than replacing the first.
+	base := "url_visited_events_" + f.From.Format(time.DateOnly)
+	name := base + ext
+	for i := 1; fileExists(filepath.Join(s.cfg.ArchiveDir, name)); i++ {
+		name = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	path := filepath.Join(s.cfg.ArchiveDir, name)
+
+	if err := os.MkdirAll(s.cfg.ArchiveDir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.cfg.ArchiveDir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var (
+		out   io.Writer = tmp
+		gz    *gzip.Writer
+		count int
+	)
+	if s.cfg.ArchiveFormat == archive.FormatNDJSON {
+		gz = gzip.NewWriter(tmp)
+		out = gz
+	}
+
+	w, err := archive.NewWriter(s.cfg.ArchiveFormat, out)
+	if err != nil {
+		return "", err
+	}
+	err = s.repository.VisitRecords(ctx, f, func(rec models.VisitRecord) error {
+		count++
+		return w.Write(rec)
+	})
+	if err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", nil
+	}
+
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return "", err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	return path, os.Rename(tmp.Name(), path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,155 @@
+package retention
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"analytics/internal/repository/memory"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"events"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 1, 10, 15, 0, 0, 0, time.UTC)
+
+// seed stores a visit at noon on each of the five days before now.
+func seed(t *testing.T) *memory.Repository {
+	repo := memory.New()
+	for i := 1; i <= 5; i++ {
+		e := events.New(events.KindVisited, "abc", now.AddDate(0, 0, -i).Truncate(24*time.Hour).Add(12*time.Hour))
+		e.UserID = "alice"
+		e.Visit = events.Visit{
+			IPAddress: "203.0.113.10",
+			UserAgent: "Mozilla/5.0",
+			VisitorID: "9f86d081884c7d659a2feaa0c55ad015",
+			Referrer:  "https://www.google.com/search?q=tiny",
+			Country:   "Germany",
+		}
+		require.NoError(t, repo.SaveVisitedEvent(context.Background(), e))
+	}
+	return repo
+}
+
+func records(t *testing.T, repo *memory.Repository) []models.VisitRecord {
+	var out []models.VisitRecord
+	require.NoError(t, repo.VisitRecords(context.Background(), repository.VisitFilter{}, func(r models.VisitRecord) error {
+		out = append(out, r)
+		return nil
+	}))
+	return out
+}
+
+func readArchive(t *testing.T, path, format string) []models.VisitRecord {
+	if format == archive.FormatParquet {
+		rows, err := parquet.ReadFile[models.VisitRecord](path)
+		require.NoError(t, err)
+		return rows
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var out []models.VisitRecord
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var r models.VisitRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		out = append(out, r)
+	}
+	require.NoError(t, scanner.Err())
+	return out
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Retention
+		files   []string
+		wantLen int
+	}{
+		{
+			name:    "delete",
+			cfg:     config.Retention{Days: 3, Mode: ModeDelete, ArchiveFormat: archive.FormatNDJSON},
+			wantLen: 3,
+		},
+		{
+			name:    "delete with ndjson archive",
+			cfg:     config.Retention{Days: 3, Mode: ModeDelete, ArchiveFormat: archive.FormatNDJSON},
+			files:   []string{"url_visited_events_2025-01-05.ndjson.gz", "url_visited_events_2025-01-06.ndjson.gz"},
+			wantLen: 3,
+		},
+		{
+			name:    "anonymize with parquet archive",
+			cfg:     config.Retention{Days: 3, Mode: ModeAnonymize, ArchiveFormat: archive.FormatParquet},
+			files:   []string{"url_visited_events_2025-01-05.parquet", "url_visited_events_2025-01-06.parquet"},
+			wantLen: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seed(t)
+			before := records(t, repo)
+			if tt.files != nil {
+				tt.cfg.ArchiveDir = t.TempDir()
+			}
+
+			s, err := New(tt.cfg, slog.New(slog.DiscardHandler), repo)
+			require.NoError(t, err)
+			require.NoError(t, s.Run(context.Background(), now))
+			// A second run finds nothing left to expire.
+			require.NoError(t, s.Run(context.Background(), now))
+
+			after := records(t, repo)
+			require.Len(t, after, tt.wantLen)
+
+			cutoff := Cutoff(tt.cfg.Days, now)
+			for _, r := range after {
+				if r.EventTime.Before(cutoff) {
+					assert.Empty(t, r.IPAddress)
+					assert.Empty(t, r.UserAgent)
+					assert.Empty(t, r.UserID)
+					assert.Empty(t, r.VisitorID)
+					assert.Equal(t, "https://www.google.com", r.Referrer)
+					assert.Equal(t, "Germany", r.Country)
+				} else {
+					assert.Equal(t, "203.0.113.10", r.IPAddress)
+				}
+			}
+
+			if tt.files == nil {
+				return
+			}
+			entries, err := os.ReadDir(tt.cfg.ArchiveDir)
+			require.NoError(t, err)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			require.Equal(t, tt.files, names)
+
+			for i, name := range names {
+				got := readArchive(t, filepath.Join(tt.cfg.ArchiveDir, name), tt.cfg.ArchiveFormat)
+				require.Len(t, got, 1)
+				assert.Equal(t, before[i].EventID, got[0].EventID)
+				assert.Equal(t, "203.0.113.10", got[0].IPAddress, "archived before anonymization")
+				assert.True(t, before[i].EventTime.Equal(got[0].EventTime))
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package stats
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+// maxSeriesPoints bounds the buckets a single series request may span.
+const maxSeriesPoints = 24 * 31
+
+// MaxLeaderboardLimit bounds the links of a top or trending leaderboard.
+const MaxLeaderboardLimit = 100
+
+// Windows are the spans a leaderboard can cover.
+var Windows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+var (
+	ErrInvalidSeries      = errors.New("invalid series request")
+	ErrInvalidLeaderboard = errors.New("invalid leaderboard request")
+)
+
+type Repository interface {
+	Stats(ctx context.Context, shortURL string, includeBots bool) (*models.Stats, error)
+	Series(ctx context.Context, shortURL, granularity string, from, to time.Time, includeBots bool) ([]models.SeriesPoint, error)
+	Owner(ctx context.Context, shortURL string) (string, error)
+	Top(ctx context.Context, from, to time.Time, limit int) ([]models.TopLink, error)
+	Trending(ctx context.Context, baselineFrom, from, to time.Time, limit int) ([]models.TopLink, error)
+}
+
+// UniqueCounter estimates unique visitors.
+type UniqueCounter interface {
+	UniqueVisitors(ctx context.Context, shortURL string, includeBots bool) (int64, error)
+	UniqueVisitorsSeries(ctx context.Context, shortURL, granularity string, buckets []time.Time, includeBots bool) ([]int64, error)
+}
+
+type Service struct {
+	cfg        config.Leaderboard
+	logger     *slog.Logger
+	repository Repository
+	uniques    UniqueCounter
+	now        func() time.Time
+
+	mu           sync.Mutex
+	leaderboards map[leaderboardKey]leaderboard
+}
+
+type leaderboardKey struct {
+	trending bool
+	window   string
+	limit    int
+}
+
+type leaderboard struct {
+	links   []models.TopLink
+	expires time.Time
+}
+
+// New returns a service reading stats from r and unique visitor estimates
+// from u. A nil u leaves the estimates out.
+func New(cfg config.Leaderboard, l *slog.Logger, r Repository, u UniqueCounter) *Service {
+	return &Service{
+		cfg:          cfg,
+		logger:       l,
+		repository:   r,
+		uniques:      u,
+		now:          time.Now,
+		leaderboards: make(map[leaderboardKey]leaderboard),
+	}
+}
+
+// Get returns the aggregated stats of shortURL, or repository.ErrNotFound
+// when no event was recorded for it. Visits from bots are left out unless
+// includeBots is set.
+func (s *Service) Get(ctx context.Context, shortURL string, includeBots bool) (*models.Stats, error) {
+	const op = "services.stats.Get"
+
+	stats, err := s.repository.Stats(ctx, shortURL, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The estimate is an extra; stats are still served without it.
+	if s.uniques != nil {
+		n, err := s.uniques.UniqueVisitors(ctx, shortURL, includeBots)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to count unique visitors", "short_url", shortURL, "error", err)
+		}
+		stats.UniqueVisitors = n
+	}
+
+	return stats, nil
+}
+
+// Owner returns the user who created shortURL, empty when it was created
+// anonymously, or repository.ErrNotFound when its creation was not recorded.
+func (s *Service) Owner(ctx context.Context, shortURL string) (string, error) {
+	const op = "services.stats.Owner"
+
+	owner, err := s.repository.Owner(ctx, shortURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return owner, nil
+}
+
+// Series returns the visits of shortURL per hour or day between from and to.
+// Buckets without visits are left out, and so are bots unless includeBots is
+// set.
+func (s *Service) Series(ctx context.Context, shortURL, granularity string, from, to time.Time, includeBots bool) ([]models.SeriesPoint, error) {
+	const op = "services.stats.Series"
+
+	var size time.Duration
+	switch granularity {
+	case repository.GranularityHour:
+		size = time.Hour
+	case repository.GranularityDay:
+		size = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("%s: %w: unknown granularity %q", op, ErrInvalidSeries, granularity)
+	}
+
+	from, to = from.UTC().Truncate(size), to.UTC()
+	switch {
+	case !from.Before(to):
+		return nil, fmt.Errorf("%s: %w: from must be before to", op, ErrInvalidSeries)
+	case to.Sub(from) > maxSeriesPoints*size:
+		return nil, fmt.Errorf("%s: %w: range spans more than %d buckets", op, ErrInvalidSeries, maxSeriesPoints)
+	}
+
+	points, err := s.repository.Series(ctx, shortURL, granularity, from, to, includeBots)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if s.uniques != nil && len(points) > 0 {
+		buckets := make([]time.Time, len(points))
+		for i, p := range points {
+			buckets[i] = p.Bucket
+		}
+
+		counts, err := s.uniques.UniqueVisitorsSeries(ctx, shortURL, granularity, buckets, includeBots)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to count unique visitors", "short_url", shortURL, "error", err)
+		}
+		for i := range counts {
+			points[i].UniqueVisitors = counts[i]
+		}
+	}
+
+	return points, nil
+}
+
+// Top returns up to limit links with the most human visits in the last
+// window, one of Windows. Only complete hours are counted, so the visits of
+// the current hour are left out until it ends.
+func (s *Service) Top(ctx context.Context, window string, limit int) ([]models.TopLink, error) {
+	const op = "services.stats.Top"
+
+	links, err := s.leaderboard(ctx, leaderboardKey{window: window, limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// Trending returns up to limit links visited in the last window, ranked by
+// how far their visits exceed the average of the repository.BaselineWindows
+// windows before. Like in Top, all windows are made of complete hours.
+func (s *Service) Trending(ctx context.Context, window string, limit int) ([]models.TopLink, error) {
+	const op = "services.stats.Trending"
+
+	links, err := s.leaderboard(ctx, leaderboardKey{trending: true, window: window, limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// leaderboard computes the leaderboard of key, or returns the copy computed
+// less than the cache TTL ago.
+func (s *Service) leaderboard(ctx context.Context, key leaderboardKey) ([]models.TopLink, error) {
+	size, ok := Windows[key.window]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w: unknown window %q", ErrInvalidLeaderboard, key.window)
+	case key.limit < 1 || key.limit > MaxLeaderboardLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidLeaderboard, MaxLeaderboardLimit)
+	}
+
+	now := s.now()
+
+	s.mu.Lock()
+	cached, ok := s.leaderboards[key]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.links, nil
+	}
+
+	// The rollups are hourly, so the windows end at the start of the current
+	// hour and the window and its baselines span the same number of hours.
+	to := repository.Bucket(now, repository.GranularityHour)
+	from := to.Add(-size)
+
+	var (
+		links []models.TopLink
+		err   error
+	)
+	if key.trending {
+		links, err = s.repository.Trending(ctx, from.Add(-repository.BaselineWindows*size), from, to, key.limit)
+	} else {
+		links, err = s.repository.Top(ctx, from, to, key.limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cfg.CacheTTL > 0 {
+		s.mu.Lock()
+		maps.DeleteFunc(s.leaderboards, func(_ leaderboardKey, l leaderboard) bool { return !now.Before(l.expires) })
+		s.leaderboards[key] = leaderboard{links: links, expires: now.Add(s.cfg.CacheTTL)}
+		s.mu.Unlock()
+	}
+
+	return links, nil
+}
@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/models"
+	"analytics/internal/repository/memory"
+	"context"
+	"events"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func visit(t *testing.T, repo *memory.Repository, shortURL string, at time.Time, bot bool, n int) {
+	for range n {
+		e := events.New(events.KindVisited, shortURL, at)
+		e.Visit.IsBot = bot
+		require.NoError(t, repo.SaveVisitedEvent(context.Background(), e))
+	}
+}
+
+func TestLeaderboards(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+	repo := memory.New()
+	// steady keeps its pace of 10 visits a day, rising had none before
+	// today, and crawled is only visited by bots.
+	visit(t, repo, "steady", now.Add(-72*time.Hour), false, 40)
+	visit(t, repo, "steady", now.Add(-time.Hour), false, 10)
+	visit(t, repo, "rising", now.Add(-time.Hour), false, 6)
+	visit(t, repo, "crawled", now.Add(-time.Hour), true, 20)
+
+	s := New(config.Leaderboard{CacheTTL: time.Minute}, slog.New(slog.DiscardHandler), repo, nil)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	top, err := s.Top(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopLink{{ShortURL: "steady", Visits: 10}, {ShortURL: "rising", Visits: 6}}, top)
+
+	trending, err := s.Trending(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopLink{
+		{ShortURL: "rising", Visits: 6, Score: 11.0 / 5},
+		{ShortURL: "steady", Visits: 10, Baseline: 10, Score: 1},
+	}, trending)
+
+	top, err = s.Top(ctx, "24h", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopLink{{ShortURL: "steady", Visits: 10}}, top)
+
+	// Within the TTL the cached leaderboard is served.
+	visit(t, repo, "rising", now.Add(-time.Hour), false, 10)
+	top, err = s.Top(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "steady", top[0].ShortURL)
+
+	uncached := New(config.Leaderboard{}, slog.New(slog.DiscardHandler), repo, nil)
+	uncached.now = s.now
+	top, err = uncached.Top(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.TopLink{ShortURL: "rising", Visits: 16}, top[0])
+
+	top, err = uncached.Top(ctx, "7d", 10)
+	require.NoError(t, err)
+	assert.Equal(t, models.TopLink{ShortURL: "steady", Visits: 50}, top[0])
+}
+
+func TestLeaderboardsCompleteHours(t *testing.T) {
+	t.Parallel()
+
+	// Half past noon: the current hour is only half over.
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+	repo := memory.New()
+	// steady is visited once an hour for five days, the current hour
+	// included, while current is only visited in the current hour.
+	for i := range 5*24 + 1 {
+		visit(t, repo, "steady", now.Add(-time.Duration(i)*time.Hour), false, 1)
+	}
+	visit(t, repo, "current", now.Add(-10*time.Minute), false, 3)
+
+	s := New(config.Leaderboard{}, slog.New(slog.DiscardHandler), repo, nil)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	top, err := s.Top(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopLink{{ShortURL: "steady", Visits: 24}}, top)
+
+	// The window and its baselines span as many hours, so a steady pace
+	// does not trend.
+	trending, err := s.Trending(ctx, "24h", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TopLink{{ShortURL: "steady", Visits: 24, Baseline: 24, Score: 1}}, trending)
+}
+
+func TestLeaderboardsInvalid(t *testing.T) {
+	t.Parallel()
+
+	s := New(config.Leaderboard{}, slog.New(slog.DiscardHandler), memory.New(), nil)
+
+	tests := []struct {
+		name   string
+		window string
+		limit  int
+	}{
+		{"unknown window", "2h", 10},
+		{"no limit", "1h", 0},
+		{"limit too high", "7d", MaxLeaderboardLimit + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Top(context.Background(), tt.window, tt.limit)
+			assert.ErrorIs(t, err, ErrInvalidLeaderboard)
+			_, err = s.Trending(context.Background(), tt.window, tt.limit)
+			assert.ErrorIs(t, err, ErrInvalidLeaderboard)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"analytics/internal/repository"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// authorizeOwner returns the owner of shortURL, or an http error unless the
+// caller may see its raw visits: the link exists and either has no owner or
+// the caller is its owner. Links created anonymously are as open as their
+// stats.
+func (s server) authorizeOwner(c echo.Context, shortURL string) (string, error) {
+	ctx := c.Request().Context()
+
+	owner, err := s.statsService.Owner(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", echo.NewHTTPError(http.StatusNotFound, Response{"URL not found"})
+		}
+
+		s.logger.ErrorContext(ctx, "failed to get owner", "short_url", shortURL, "error", err)
+		return "", echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get URL"})
+	}
+	if owner != "" && !s.isUser(c, owner) {
+		return "", echo.NewHTTPError(http.StatusForbidden, Response{"Only the owner can access this URL"})
+	}
+
+	return owner, nil
+}
+
+// isUser reports whether the bearer token of the request was issued to user.
+func (s server) isUser(c echo.Context, user string) bool {
+	caller, ok := s.caller(c)
+	return ok && caller == user
+}
+
+// caller returns the subject of the bearer token of the request. Tokens
+// must be signed with AuthSecret using HS256 and carry an expiry; anything
+// else, including a missing secret, leaves the caller unidentified.
+func (s server) caller(c echo.Context) (string, bool) {
+	secret := s.cfg.HttpServer.AuthSecret
+	raw, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if secret == "" || !ok {
+		return "", false
+	}
+
+	token, err := jwt.Parse(raw, func(*jwt.Token) (any, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		s.logger.DebugContext(c.Request().Context(), "bearer token rejected", "error", err)
+		return "", false
+	}
+
+	sub, err := token.Claims.GetSubject()
+	if err != nil || sub == "" {
+		return "", false
+	}
+
+	return sub, true
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"analytics/internal/archive"
+	"analytics/internal/services/export"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ExportService interface {
+	Export(ctx context.Context, w io.Writer, q export.Query) (int64, error)
+}
+
+var exportContentTypes = map[string]string{
+	archive.FormatCSV:     "text/csv",
+	archive.FormatNDJSON:  "application/x-ndjson",
+	archive.FormatParquet: "application/vnd.apache.parquet",
+}
+
+// HandleExportGet streams raw visits as a file download. Query parameters:
+// short_url or owner, from and to as RFC 3339 times or dates, format (csv,
+// ndjson or parquet, default csv), columns as a comma separated list and
+// gzip. Like the live streams, a link with an owner, and the links of an
+// owner, can only be exported by that user, identified by a bearer token.
+// Only the visits made while the owner held an alias are exported.
+func (s server) HandleExportGet(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	q := export.Query{
+		ShortURL: c.QueryParam("short_url"),
+		Owner:    c.QueryParam("owner"),
+		Format:   c.QueryParam("format"),
+	}
+	if q.Format == "" {
+		q.Format = archive.FormatCSV
+	}
+	if columns := c.QueryParam("columns"); columns != "" {
+		q.Columns = strings.Split(columns, ",")
+	}
+
+	var err error
+	if q.From, err = parseTime(c.QueryParam("from"), time.Time{}); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid from: " + err.Error()})
+	}
+	if q.To, err = parseTime(c.QueryParam("to"), time.Time{}); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid to: " + err.Error()})
+	}
+	if q.Gzip, err = parseBool(c.QueryParam("gzip")); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid gzip: " + err.Error()})
+	}
+
+	if q.Owner != "" && !s.isUser(c, q.Owner) {
+		return echo.NewHTTPError(http.StatusForbidden, Response{"Only the owner can export their URLs"})
+	}
+	if q.ShortURL != "" {
+		owner, err := s.authorizeOwner(c, q.ShortURL)
+		if err != nil {
+			return err
+		}
+		// The alias may have belonged to someone else before; their visits
+		// are left out.
+		if owner != "" {
+			q.Owner = owner
+		}
+	}
+
+	// A large export outlives the write timeout of regular requests.
+	if err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.WarnContext(ctx, "failed to clear write deadline", "error", err)
+	}
+
+	res := c.Response()
+	contentType := exportContentTypes[q.Format]
+	if q.Gzip {
+		contentType = "application/gzip"
+	}
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="visits`+q.Ext()+`"`)
+
+	n, err := s.exportService.Export(ctx, res, q)
+	if err != nil {
+		if res.Committed {
+			// Too late for an error status: the client gets a truncated
+			// file.
+			s.logger.ErrorContext(ctx, "export failed", "exported", n, "error", err)
+			return nil
+		}
+
+		res.Header().Del(echo.HeaderContentDisposition)
+		if errors.Is(err, export.ErrInvalidExport) {
+			return echo.NewHTTPError(http.StatusBadRequest, Response{err.Error()})
+		}
+
+		s.logger.ErrorContext(ctx, "export failed", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to export visits"})
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package httpserver
+
+import (
+	"analytics/internal/services/live"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type LiveService interface {
+	Subscribe(shortURL string) *live.Subscription
+	Close()
+}
+
+// HandleLiveGet streams the visits of a link as Server-Sent Events while
+// they are consumed: a "visit" event per visit and, when the client fell
+// behind, a "dropped" event with the number of visits it missed. Bots are
+// left out unless include_bots is true. Links with an owner can only be
+// followed by that user.
+func (s server) HandleLiveGet(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shortURL := c.Param("short_url")
+	if strings.TrimSpace(shortURL) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Short URL cannot be empty"})
+	}
+
+	includeBots, err := parseBool(c.QueryParam("include_bots"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid include_bots: " + err.Error()})
+	}
+
+	if _, err := s.authorizeOwner(c, shortURL); err != nil {
+		return err
+	}
+
+	// The stream outlives the write timeout of regular requests.
+	if err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.WarnContext(ctx, "failed to clear write deadline", "error", err)
+	}
+
+	sub := s.liveService.Subscribe(shortURL)
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(s.cfg.Live.KeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case visit, ok := <-sub.Visits():
+			if !ok {
+				// The server is shutting down.
+				return nil
+			}
+			if visit.IsBot && !includeBots {
+				continue
+			}
+
+			if n := sub.Dropped(); n > 0 {
+				if err := writeEvent(res, "dropped", map[string]int64{"count": n}); err != nil {
+					return nil
+				}
+			}
+			if err := writeEvent(res, "visit", visit); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+func writeEvent(res *echo.Response, name string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
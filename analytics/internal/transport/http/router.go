@@ -0,0 +1,61 @@
+package httpserver
+
+import (
+	"analytics/internal/metrics"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+func (s server) registerRoutes(e *echo.Echo) {
+	e.Use(middleware.RequestID())
+	e.Use(otelecho.Middleware(s.cfg.AppName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/up":
+			return true
+		}
+		return false
+	})))
+	e.Use(middleware.Recover())
+
+	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogStatus:   true,
+		LogURI:      true,
+		LogError:    true,
+		HandleError: true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			if v.Error == nil {
+				s.logger.LogAttrs(c.Request().Context(), slog.LevelInfo, "REQUEST",
+					slog.String("uri", v.URI),
+					slog.Int("status", v.Status),
+				)
+			} else {
+				s.logger.LogAttrs(c.Request().Context(), slog.LevelError, "REQUEST_ERROR",
+					slog.String("uri", v.URI),
+					slog.Int("status", v.Status),
+					slog.String("err", v.Error.Error()),
+				)
+			}
+			return nil
+		},
+	}))
+
+	e.GET("/up", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	// Static routes win over parameters; the shortener reserves the alias
+	// "top" so no link is shadowed.
+	e.GET("/stats/top", s.HandleTopGet)
+	e.GET("/stats/:short_url", s.HandleStatsGet)
+	e.GET("/stats/:short_url/series", s.HandleSeriesGet)
+	if s.liveService != nil {
+		e.GET("/stats/:short_url/live", s.HandleLiveGet)
+	}
+	if s.exportService != nil {
+		e.GET("/export", s.HandleExportGet)
+	}
+}
@@ -0,0 +1,62 @@
+package httpserver
+
+import (
+	"analytics/internal/config"
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type server struct {
+	cfg           *config.Config
+	logger        *slog.Logger
+	statsService  StatsService
+	liveService   LiveService
+	exportService ExportService
+	srv           *http.Server
+}
+
+// New returns the http server. The live streams and exports are only served
+// when ls and es are not nil. Live streams are closed when the server shuts
+// down.
+func New(cfg *config.Config, l *slog.Logger, ss StatsService, ls LiveService, es ExportService) server {
+	e := echo.New()
+	s := &http.Server{
+		Addr:         cfg.HttpServer.Address,
+		Handler:      e,
+		ReadTimeout:  cfg.HttpServer.TimeOut,
+		WriteTimeout: cfg.HttpServer.TimeOut,
+		IdleTimeout:  cfg.HttpServer.IdleTimeout,
+	}
+
+	server := server{
+		cfg:           cfg,
+		logger:        l,
+		statsService:  ss,
+		liveService:   ls,
+		exportService: es,
+		srv:           s,
+	}
+	if ls != nil {
+		s.RegisterOnShutdown(ls.Close)
+	}
+
+	server.registerRoutes(e)
+
+	return server
+}
+
+// Handler exposes the routes without a listener, for use with httptest.
+func (s server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+func (s server) Run() error {
+	return s.srv.ListenAndServe()
+}
+
+func (s server) Stop(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,160 @@
+package httpserver
+
+import (
+	"analytics/internal/models"
+	"analytics/internal/repository"
+	"analytics/internal/services/stats"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Response struct {
+	Message any `json:"message"`
+}
+
+type StatsService interface {
+	Get(ctx context.Context, shortURL string, includeBots bool) (*models.Stats, error)
+	Series(ctx context.Context, shortURL, granularity string, from, to time.Time, includeBots bool) ([]models.SeriesPoint, error)
+	Owner(ctx context.Context, shortURL string) (string, error)
+	Top(ctx context.Context, window string, limit int) ([]models.TopLink, error)
+	Trending(ctx context.Context, window string, limit int) ([]models.TopLink, error)
+}
+
+// defaultSeriesRange is the span of a series request without from.
+var defaultSeriesRange = map[string]time.Duration{
+	repository.GranularityHour: 24 * time.Hour,
+	repository.GranularityDay:  30 * 24 * time.Hour,
+}
+
+// HandleStatsGet returns the aggregated stats. Bots are left out unless the
+// include_bots query parameter is true.
+func (s server) HandleStatsGet(c echo.Context) error {
+	shortURL := c.Param("short_url")
+	if strings.TrimSpace(shortURL) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Short URL cannot be empty"})
+	}
+
+	includeBots, err := parseBool(c.QueryParam("include_bots"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid include_bots: " + err.Error()})
+	}
+
+	stats, err := s.statsService.Get(c.Request().Context(), shortURL, includeBots)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, Response{"No stats for this URL"})
+		}
+
+		s.logger.ErrorContext(c.Request().Context(), "failed to get stats", "short_url", shortURL, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get stats"})
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// HandleSeriesGet returns the visits per bucket. Query parameters:
+// granularity (hour or day, default day), from and to as RFC 3339 times or
+// dates, and include_bots. to defaults to now and from to a day or 30 days
+// before to.
+func (s server) HandleSeriesGet(c echo.Context) error {
+	shortURL := c.Param("short_url")
+	if strings.TrimSpace(shortURL) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Short URL cannot be empty"})
+	}
+
+	granularity := c.QueryParam("granularity")
+	if granularity == "" {
+		granularity = repository.GranularityDay
+	}
+
+	to, err := parseTime(c.QueryParam("to"), time.Now())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid to: " + err.Error()})
+	}
+	from, err := parseTime(c.QueryParam("from"), to.Add(-defaultSeriesRange[granularity]))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid from: " + err.Error()})
+	}
+	includeBots, err := parseBool(c.QueryParam("include_bots"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid include_bots: " + err.Error()})
+	}
+
+	points, err := s.statsService.Series(c.Request().Context(), shortURL, granularity, from, to, includeBots)
+	if err != nil {
+		if errors.Is(err, stats.ErrInvalidSeries) {
+			return echo.NewHTTPError(http.StatusBadRequest, Response{err.Error()})
+		}
+
+		s.logger.ErrorContext(c.Request().Context(), "failed to get series", "short_url", shortURL, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get series"})
+	}
+
+	return c.JSON(http.StatusOK, points)
+}
+
+// HandleTopGet returns the leaderboard of links over a window. Query
+// parameters: window (1h, 24h or 7d, default 24h), limit (default 10) and
+// sort, either clicks for the most visited links or trending for the links
+// whose visits grew the most against their baseline. Bots are left out.
+func (s server) HandleTopGet(c echo.Context) error {
+	window := c.QueryParam("window")
+	if window == "" {
+		window = "24h"
+	}
+
+	limit := 10
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid limit: " + err.Error()})
+		}
+	}
+
+	var (
+		links []models.TopLink
+		err   error
+	)
+	switch sort := c.QueryParam("sort"); sort {
+	case "", "clicks":
+		links, err = s.statsService.Top(c.Request().Context(), window, limit)
+	case "trending":
+		links, err = s.statsService.Trending(c.Request().Context(), window, limit)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Invalid sort: " + sort})
+	}
+	if err != nil {
+		if errors.Is(err, stats.ErrInvalidLeaderboard) {
+			return echo.NewHTTPError(http.StatusBadRequest, Response{err.Error()})
+		}
+
+		s.logger.ErrorContext(c.Request().Context(), "failed to get top links", "window", window, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get top links"})
+	}
+
+	return c.JSON(http.StatusOK, links)
+}
+
+// parseBool parses an optional boolean query parameter, false when empty.
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
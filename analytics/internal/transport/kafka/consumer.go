@@ -0,0 +1,215 @@
+package kafka
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/metrics"
+	"context"
+	"errors"
+	"events"
+	"fmt"
+	"log/slog"
+	"platform/kafkaheaders"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const readTimeout = 500 * time.Millisecond
+
+var tracer = otel.Tracer("analytics/internal/transport/kafka")
+
+type EventHandler interface {
+	Handle(ctx context.Context, event events.UrlEvent) error
+}
+
+// Reader is the part of *kafka.Consumer the consumer loop relies on. Tests
+// implement it to feed messages without a broker.
+type Reader interface {
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	Close() error
+}
+
+// DeadLetterWriter receives the messages the consumer gives up on.
+// *DeadLetters implements it.
+type DeadLetterWriter interface {
+	Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error
+}
+
+type Consumer struct {
+	cfg         *config.Config
+	logger      *slog.Logger
+	consumer    Reader
+	handler     EventHandler
+	deadLetters DeadLetterWriter
+}
+
+func NewConsumer(cfg *config.Config, l *slog.Logger, h EventHandler, dlq DeadLetterWriter) (*Consumer, error) {
+	const op = "kafka.NewConsumer"
+
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": strings.Join(cfg.MsgBroker.Addr, ","),
+		"group.id":          cfg.MsgBroker.GroupID,
+		"auto.offset.reset": "earliest",
+		// Offsets are stored only after a message was handled, so a crash
+		// replays it instead of losing it.
+		"enable.auto.offset.store": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.SubscribeTopics([]string{cfg.MsgBroker.Topic}, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return NewConsumerFromReader(cfg, l, c, h, dlq), nil
+}
+
+// NewConsumerFromReader builds a consumer on top of an already subscribed
+// reader.
+func NewConsumerFromReader(cfg *config.Config, l *slog.Logger, r Reader, h EventHandler, dlq DeadLetterWriter) *Consumer {
+	return &Consumer{
+		cfg:         cfg,
+		logger:      l,
+		consumer:    r,
+		handler:     h,
+		deadLetters: dlq,
+	}
+}
+
+// Run consumes messages until ctx is cancelled or a fatal error occurs. A
+// message that cannot be decoded or holds an invalid event is sent to the
+// dead-letter topic so it does not block the partition. Transient failures,
+// such as the database being down, are retried until they pass, without
+// storing the offset. Run only stops on a failure when the dead-letter topic
+// cannot be written.
+func (c *Consumer) Run(ctx context.Context) error {
+	const op = "kafka.Consumer.Run"
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		msg, err := c.consumer.ReadMessage(readTimeout)
+		if err != nil {
+			var kafkaErr kafka.Error
+			if errors.As(err, &kafkaErr) {
+				if kafkaErr.IsTimeout() {
+					continue
+				}
+				if kafkaErr.IsFatal() {
+					return fmt.Errorf("%s: %w", op, err)
+				}
+			}
+			c.logger.Error("failed to read message", "error", err)
+			continue
+		}
+
+		if err := c.process(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				// Shutting down mid-retry: the offset is not stored, so the
+				// message is delivered again after a restart.
+				return nil
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err := c.consumer.StoreMessage(msg); err != nil {
+			c.logger.Error("failed to store offset", "offset", msg.TopicPartition.String(), "error", err)
+		}
+	}
+}
+
+// process handles msg and dead-letters it when it can never be stored. An
+// error means the message was neither stored nor dead-lettered.
+func (c *Consumer) process(ctx context.Context, msg *kafka.Message) error {
+	topic := *msg.TopicPartition.Topic
+
+	// Continue the trace started by the producer.
+	ctx = otel.GetTextMapPropagator().Extract(ctx, kafkaheaders.NewCarrier(&msg.Headers))
+	ctx, span := tracer.Start(ctx, topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+			semconv.MessagingKafkaMessageOffset(int(msg.TopicPartition.Offset)),
+		),
+	)
+	defer span.End()
+
+	attempts, err := c.handle(ctx, msg)
+	if err == nil {
+		return nil
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	c.logger.ErrorContext(ctx, "failed to process message, sending to dead-letter topic",
+		"offset", msg.TopicPartition.String(), "attempts", attempts, "error", err)
+
+	if err := c.deadLetters.Send(ctx, msg, err, attempts); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	metrics.DeadLetters.WithLabelValues(topic).Inc()
+
+	return nil
+}
+
+// handle decodes msg and passes the event to the handler, retrying with
+// exponential backoff until it succeeds, the failure turns out permanent or
+// ctx is done. It returns the number of attempts made.
+func (c *Consumer) handle(ctx context.Context, msg *kafka.Message) (int, error) {
+	// Producers record the encoding in a header. Messages from before the
+	// header existed have none and are JSON.
+	contentType := kafkaheaders.NewCarrier(&msg.Headers).Get(events.ContentTypeHeader)
+	event, err := events.Unmarshal(msg.Value, contentType)
+	if err != nil {
+		return 1, fmt.Errorf("decode %s message: %w", contentType, err)
+	}
+
+	backoff := c.cfg.MsgBroker.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.handler.Handle(ctx, event)
+		if err == nil || permanent(err) {
+			return attempt, err
+		}
+
+		c.logger.WarnContext(ctx, "failed to handle event, retrying",
+			"offset", msg.TopicPartition.String(), "attempt", attempt, "backoff", backoff, "error", err)
+		metrics.Retries.WithLabelValues(*msg.TopicPartition.Topic).Inc()
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.cfg.MsgBroker.RetryMaxBackoff)
+	}
+}
+
+// permanent reports whether retrying err cannot help because the event
+// itself is the problem.
+func permanent(err error) bool {
+	return errors.Is(err, events.ErrInvalidEvent) || errors.Is(err, events.ErrUnknownKind)
+}
+
+func (c *Consumer) Close() error {
+	return c.consumer.Close()
+}
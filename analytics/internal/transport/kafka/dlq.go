@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"analytics/internal/config"
+	"context"
+	"fmt"
+	"platform/kafkaheaders"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Headers added to dead-lettered messages. The original headers, key and
+// value are kept as they were, so a replayed message is identical to the one
+// that failed.
+const (
+	HeaderDLQError     = "dlq.error"
+	HeaderDLQTopic     = "dlq.original_topic"
+	HeaderDLQPartition = "dlq.original_partition"
+	HeaderDLQOffset    = "dlq.original_offset"
+	HeaderDLQAttempts  = "dlq.attempts"
+	HeaderDLQFailedAt  = "dlq.failed_at"
+	// HeaderDLQReplay names the replay that sent a message back. Unlike the
+	// others it stays on the replayed message, so a replay recognizes the
+	// messages it sent that failed again.
+	HeaderDLQReplay = "dlq.replay"
+)
+
+// DeadLetters publishes messages the consumer gave up on to the dead-letter
+// topic.
+type DeadLetters struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+func NewDeadLetters(cfg *config.Config) (*DeadLetters, error) {
+	const op = "kafka.NewDeadLetters"
+
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": strings.Join(cfg.MsgBroker.Addr, ","),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &DeadLetters{producer: p, topic: cfg.MsgBroker.DLQTopic}, nil
+}
+
+// Send publishes msg to the dead-letter topic with the cause and the number
+// of attempts in its headers, and waits for the delivery report.
+func (d *DeadLetters) Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error {
+	const op = "kafka.DeadLetters.Send"
+
+	if err := produceSync(d.producer, deadLetter(d.topic, msg, cause, attempts, time.Now())); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (d *DeadLetters) Close() {
+	d.producer.Close()
+}
+
+func deadLetter(topic string, msg *kafka.Message, cause error, attempts int, at time.Time) *kafka.Message {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	carrier := kafkaheaders.NewCarrier(&headers)
+
+	carrier.Set(HeaderDLQError, cause.Error())
+	carrier.Set(HeaderDLQTopic, *msg.TopicPartition.Topic)
+	carrier.Set(HeaderDLQPartition, strconv.Itoa(int(msg.TopicPartition.Partition)))
+	carrier.Set(HeaderDLQOffset, msg.TopicPartition.Offset.String())
+	carrier.Set(HeaderDLQAttempts, strconv.Itoa(attempts))
+	carrier.Set(HeaderDLQFailedAt, at.UTC().Format(time.RFC3339))
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}
+}
+
+// DeadLetter is a message read back from the dead-letter topic. Replay is
+// set on messages that failed again after a replay.
+type DeadLetter struct {
+	Offset            string            `json:"offset"`
+	Key               string            `json:"key"`
+	Error             string            `json:"error"`
+	Attempts          string            `json:"attempts"`
+	FailedAt          string            `json:"failed_at"`
+	OriginalTopic     string            `json:"original_topic"`
+	OriginalPartition string            `json:"original_partition"`
+	OriginalOffset    string            `json:"original_offset"`
+	Replay            string            `json:"replay,omitempty"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	Value             string            `json:"value"`
+}
+
+func parseDeadLetter(msg *kafka.Message) DeadLetter {
+	carrier := kafkaheaders.NewCarrier(&msg.Headers)
+
+	dl := DeadLetter{
+		Offset:            msg.TopicPartition.String(),
+		Key:               string(msg.Key),
+		Error:             carrier.Get(HeaderDLQError),
+		Attempts:          carrier.Get(HeaderDLQAttempts),
+		FailedAt:          carrier.Get(HeaderDLQFailedAt),
+		OriginalTopic:     carrier.Get(HeaderDLQTopic),
+		OriginalPartition: carrier.Get(HeaderDLQPartition),
+		OriginalOffset:    carrier.Get(HeaderDLQOffset),
+		Replay:            carrier.Get(HeaderDLQReplay),
+		Value:             string(msg.Value),
+	}
+	for _, h := range msg.Headers {
+		if !strings.HasPrefix(h.Key, "dlq.") {
+			if dl.Headers == nil {
+				dl.Headers = make(map[string]string)
+			}
+			dl.Headers[h.Key] = string(h.Value)
+		}
+	}
+
+	return dl
+}
+
+// replayMessage restores the message that was dead-lettered: same key, value
+// and headers, addressed to its original topic, or to fallback when the
+// header is missing. The message is marked as sent by replay.
+func replayMessage(msg *kafka.Message, fallback, replay string) *kafka.Message {
+	topic := kafkaheaders.NewCarrier(&msg.Headers).Get(HeaderDLQTopic)
+	if topic == "" {
+		topic = fallback
+	}
+
+	var headers []kafka.Header
+	for _, h := range msg.Headers {
+		if !strings.HasPrefix(h.Key, "dlq.") {
+			headers = append(headers, h)
+		}
+	}
+	kafkaheaders.NewCarrier(&headers).Set(HeaderDLQReplay, replay)
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}
+}
+
+func produceSync(p *kafka.Producer, msg *kafka.Message) error {
+	delivery := make(chan kafka.Event, 1)
+	if err := p.Produce(msg, delivery); err != nil {
+		return err
+	}
+
+	switch ev := (<-delivery).(type) {
+	case *kafka.Message:
+		return ev.TopicPartition.Error
+	case kafka.Error:
+		return ev
+	default:
+		return fmt.Errorf("unexpected delivery event %v", ev)
+	}
+}
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"analytics/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"platform/kafkaheaders"
+	"strings"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// adminIdleTimeout ends a DLQ read once the topic has been quiet this long.
+// It is generous because the first reads wait for the partition assignment.
+const adminIdleTimeout = 10 * time.Second
+
+// DLQAdmin reads the dead-letter topic for the dlq command. It uses its own
+// consumer group, so inspecting shows the messages not yet replayed and a
+// replay resumes where the previous one stopped.
+type DLQAdmin struct {
+	cfg      *config.Config
+	consumer *kafka.Consumer
+	producer *kafka.Producer
+}
+
+func NewDLQAdmin(cfg *config.Config) (*DLQAdmin, error) {
+	const op = "kafka.NewDLQAdmin"
+
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  strings.Join(cfg.MsgBroker.Addr, ","),
+		"group.id":           cfg.MsgBroker.GroupID + "-dlq-replay",
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.SubscribeTopics([]string{cfg.MsgBroker.DLQTopic}, nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": strings.Join(cfg.MsgBroker.Addr, ","),
+	})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &DLQAdmin{cfg: cfg, consumer: c, producer: p}, nil
+}
+
+// Inspect calls fn for up to limit pending dead letters without marking them
+// as handled. A limit of 0 reads until the topic is idle.
+func (a *DLQAdmin) Inspect(ctx context.Context, limit int, fn func(DeadLetter) error) error {
+	const op = "kafka.DLQAdmin.Inspect"
+
+	return a.read(ctx, op, limit, func(msg *kafka.Message) error {
+		return fn(parseDeadLetter(msg))
+	})
+}
+
+// Replay republishes up to limit pending dead letters to their original
+// topic and commits each one after it was delivered. It returns the number
+// of replayed messages. The consumer drops events it already stored, so
+// replaying a message twice is harmless. A message that fails again comes
+// back to the dead-letter topic; its partition is paused then, leaving it
+// and the messages after it for a later replay.
+func (a *DLQAdmin) Replay(ctx context.Context, limit int) (int, error) {
+	const op = "kafka.DLQAdmin.Replay"
+
+	replay := time.Now().UTC().Format(time.RFC3339Nano)
+	paused := make(map[int32]bool)
+
+	var replayed int
+	err := a.read(ctx, op, limit, func(msg *kafka.Message) error {
+		partition := msg.TopicPartition.Partition
+		if paused[partition] {
+			return nil
+		}
+		if kafkaheaders.NewCarrier(&msg.Headers).Get(HeaderDLQReplay) == replay {
+			paused[partition] = true
+			return a.consumer.Pause([]kafka.TopicPartition{msg.TopicPartition})
+		}
+
+		if err := produceSync(a.producer, replayMessage(msg, a.cfg.MsgBroker.Topic, replay)); err != nil {
+			return err
+		}
+		if _, err := a.consumer.CommitMessage(msg); err != nil {
+			return err
+		}
+		replayed++
+		return nil
+	})
+
+	return replayed, err
+}
+
+func (a *DLQAdmin) read(ctx context.Context, op string, limit int, fn func(*kafka.Message) error) error {
+	for n := 0; limit == 0 || n < limit; n++ {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		msg, err := a.consumer.ReadMessage(adminIdleTimeout)
+		if err != nil {
+			var kafkaErr kafka.Error
+			if errors.As(err, &kafkaErr) && kafkaErr.IsTimeout() {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := fn(msg); err != nil {
+			return fmt.Errorf("%s: %s: %w", op, msg.TopicPartition, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *DLQAdmin) Close() {
+	a.producer.Flush(int(adminIdleTimeout.Milliseconds()))
+	a.producer.Close()
+	a.consumer.Close()
+}
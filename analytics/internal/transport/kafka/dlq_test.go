@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterReplay(t *testing.T) {
+	topic := "url_events"
+	original := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 42},
+		Key:            []byte("abc"),
+		Value:          []byte(`{"event_type":"visited"}`),
+		Headers:        []kafka.Header{{Key: "content-type", Value: []byte("application/json")}},
+	}
+
+	dl := deadLetter("url_events.dlq", original, errors.New("boom"), 6, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "url_events.dlq", *dl.TopicPartition.Topic)
+	assert.Equal(t, DeadLetter{
+		Offset:            dl.TopicPartition.String(),
+		Key:               "abc",
+		Error:             "boom",
+		Attempts:          "6",
+		FailedAt:          "2025-01-01T12:00:00Z",
+		OriginalTopic:     "url_events",
+		OriginalPartition: "3",
+		OriginalOffset:    "42",
+		Headers:           map[string]string{"content-type": "application/json"},
+		Value:             `{"event_type":"visited"}`,
+	}, parseDeadLetter(dl))
+	assert.Len(t, original.Headers, 1, "the consumed message is not modified")
+
+	replay := replayMessage(dl, "fallback", "replay-1")
+	assert.Equal(t, "url_events", *replay.TopicPartition.Topic)
+	assert.Equal(t, original.Key, replay.Key)
+	assert.Equal(t, original.Value, replay.Value)
+	assert.Equal(t, append(original.Headers, kafka.Header{Key: HeaderDLQReplay, Value: []byte("replay-1")}), replay.Headers)
+
+	// A replayed message that fails again is dead-lettered with the mark of
+	// the replay that sent it, which the next replay replaces.
+	again := deadLetter("url_events.dlq", replay, errors.New("boom"), 1, time.Now())
+	assert.Equal(t, "replay-1", parseDeadLetter(again).Replay)
+	assert.Equal(t, map[string]string{"content-type": "application/json"}, parseDeadLetter(again).Headers)
+	next := replayMessage(again, "fallback", "replay-2")
+	assert.Equal(t, append(original.Headers, kafka.Header{Key: HeaderDLQReplay, Value: []byte("replay-2")}), next.Headers)
+}
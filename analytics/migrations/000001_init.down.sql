@@ -1 +1,3 @@
-DROP TABLE IF EXISTS "analytics";
\ No newline at end of file
+DROP TABLE IF EXISTS url_visited_events;
+DROP TABLE IF EXISTS url_deleted_events;
+DROP TABLE IF EXISTS url_created_events;
@@ -0,0 +1 @@
+ALTER TABLE url_visited_events ALTER COLUMN country TYPE CHAR(2) USING LEFT(country, 2);
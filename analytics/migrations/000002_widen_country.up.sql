@@ -0,0 +1,2 @@
+-- url-shortener publishes the full country name, not an ISO code.
+ALTER TABLE url_visited_events ALTER COLUMN country TYPE VARCHAR(100);
@@ -0,0 +1,3 @@
+ALTER TABLE url_visited_events DROP COLUMN IF EXISTS event_id;
+ALTER TABLE url_deleted_events DROP COLUMN IF EXISTS event_id;
+ALTER TABLE url_created_events DROP COLUMN IF EXISTS event_id;
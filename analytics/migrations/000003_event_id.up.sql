@@ -0,0 +1,17 @@
+-- Events carry a unique ID since schema version 2. Storing it with a unique
+-- constraint makes redelivered events a no-op instead of a second row.
+-- Rows stored before the ID existed get a random one.
+ALTER TABLE url_created_events ADD COLUMN event_id TEXT;
+UPDATE url_created_events SET event_id = gen_random_uuid()::text WHERE event_id IS NULL;
+ALTER TABLE url_created_events ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE url_created_events ADD CONSTRAINT url_created_events_event_id_key UNIQUE (event_id);
+
+ALTER TABLE url_deleted_events ADD COLUMN event_id TEXT;
+UPDATE url_deleted_events SET event_id = gen_random_uuid()::text WHERE event_id IS NULL;
+ALTER TABLE url_deleted_events ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE url_deleted_events ADD CONSTRAINT url_deleted_events_event_id_key UNIQUE (event_id);
+
+ALTER TABLE url_visited_events ADD COLUMN event_id TEXT;
+UPDATE url_visited_events SET event_id = gen_random_uuid()::text WHERE event_id IS NULL;
+ALTER TABLE url_visited_events ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE url_visited_events ADD CONSTRAINT url_visited_events_event_id_key UNIQUE (event_id);
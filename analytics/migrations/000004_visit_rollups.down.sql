@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS url_visit_rollups;
+DROP FUNCTION IF EXISTS referer_host(TEXT);
@@ -0,0 +1,35 @@
+-- referer_host reduces a referer to its lower-cased host, '' for direct visits
+-- and values that are not absolute urls.
+CREATE OR REPLACE FUNCTION referer_host(referer TEXT) RETURNS TEXT
+    LANGUAGE SQL IMMUTABLE
+    AS $$ SELECT COALESCE(lower(substring(referer FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)')), '') $$;
+
+-- Visit counts per short url and hourly or daily UTC bucket. The row with an
+-- empty dimension is the total; the others break it down by one dimension.
+-- The consumer keeps the table current and "analytics rollups backfill"
+-- rebuilds it from url_visited_events.
+CREATE TABLE IF NOT EXISTS url_visit_rollups (
+    granularity TEXT NOT NULL CHECK (granularity IN ('hour', 'day')),
+    bucket TIMESTAMPTZ NOT NULL,
+    short_url TEXT NOT NULL,
+    dimension TEXT NOT NULL,
+    value TEXT NOT NULL,
+    visits BIGINT NOT NULL,
+    PRIMARY KEY (short_url, granularity, dimension, bucket, value)
+);
+CREATE INDEX IF NOT EXISTS idx_url_visit_rollups_bucket ON url_visit_rollups(granularity, bucket);
+
+INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, visits)
+SELECT g.granularity, date_trunc(g.granularity, e.event_time, 'UTC'), e.short_url, d.dimension, d.value, count(*)
+FROM url_visited_events e
+CROSS JOIN (VALUES ('hour'), ('day')) AS g(granularity)
+CROSS JOIN LATERAL (VALUES
+    ('', ''),
+    ('country', COALESCE(e.country, '')),
+    ('device_type', COALESCE(e.device_type, '')),
+    ('browser', COALESCE(e.browser, '')),
+    ('os', COALESCE(e.os, '')),
+    ('referer_host', referer_host(e.referer))
+) AS d(dimension, value)
+WHERE e.short_url IS NOT NULL AND e.event_time IS NOT NULL
+GROUP BY 1, 2, 3, 4, 5;
@@ -0,0 +1 @@
+ALTER TABLE url_visited_events DROP COLUMN IF EXISTS visitor_id;
@@ -0,0 +1,4 @@
+-- Visits carry a pseudonymous visitor ID when the shortener hashes IPs
+-- instead of sending them. It rotates daily, so it only links visits made on
+-- the same day.
+ALTER TABLE url_visited_events ADD COLUMN visitor_id TEXT;
@@ -0,0 +1,19 @@
+-- Bot and human visits are added back together, keeping the buckets whose
+-- raw events retention has already deleted.
+CREATE TEMPORARY TABLE merged_rollups AS
+SELECT granularity, bucket, short_url, dimension, value, sum(visits)::bigint AS visits
+FROM url_visit_rollups
+GROUP BY 1, 2, 3, 4, 5;
+
+DELETE FROM url_visit_rollups;
+ALTER TABLE url_visit_rollups DROP CONSTRAINT url_visit_rollups_pkey;
+ALTER TABLE url_visit_rollups DROP COLUMN IF EXISTS is_bot;
+ALTER TABLE url_visit_rollups ADD PRIMARY KEY (short_url, granularity, dimension, bucket, value);
+
+INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, visits)
+SELECT granularity, bucket, short_url, dimension, value, visits
+FROM merged_rollups;
+
+DROP TABLE merged_rollups;
+
+ALTER TABLE url_visited_events DROP COLUMN IF EXISTS is_bot;
@@ -0,0 +1,45 @@
+-- Visits from crawlers and link-preview fetchers are stored but left out of
+-- stats unless asked for. Before producers flagged them, the user agent
+-- library already labelled most of them with the Bot device type.
+ALTER TABLE url_visited_events ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE url_visited_events SET is_bot = TRUE WHERE device_type = 'Bot';
+
+-- Rollups count bots and humans separately, so stats can leave bots out
+-- without reading the raw events. The existing rows mix both and are counted
+-- as human visits.
+ALTER TABLE url_visit_rollups ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE url_visit_rollups DROP CONSTRAINT url_visit_rollups_pkey;
+ALTER TABLE url_visit_rollups ADD PRIMARY KEY (short_url, granularity, dimension, bucket, value, is_bot);
+
+-- The buckets the raw events still fully cover are rebuilt with bots split
+-- out. Retention may have deleted older events, and with them the only way
+-- to split the older buckets, so those are kept as they are. The bucket of
+-- the oldest event may be partly deleted and is kept too.
+CREATE TEMPORARY TABLE rebuilt_buckets AS
+SELECT g.granularity, date_trunc(g.granularity, min(e.event_time), 'UTC') + ('1 ' || g.granularity)::interval AS since
+FROM url_visited_events e
+CROSS JOIN (VALUES ('hour'), ('day')) AS g(granularity)
+WHERE e.event_time IS NOT NULL
+GROUP BY g.granularity;
+
+DELETE FROM url_visit_rollups r
+USING rebuilt_buckets b
+WHERE r.granularity = b.granularity AND r.bucket >= b.since;
+
+INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, is_bot, visits)
+SELECT g.granularity, date_trunc(g.granularity, e.event_time, 'UTC'), e.short_url, d.dimension, d.value, e.is_bot, count(*)
+FROM url_visited_events e
+CROSS JOIN (VALUES ('hour'), ('day')) AS g(granularity)
+JOIN rebuilt_buckets b ON b.granularity = g.granularity
+CROSS JOIN LATERAL (VALUES
+    ('', ''),
+    ('country', COALESCE(e.country, '')),
+    ('device_type', COALESCE(e.device_type, '')),
+    ('browser', COALESCE(e.browser, '')),
+    ('os', COALESCE(e.os, '')),
+    ('referer_host', referer_host(e.referer))
+) AS d(dimension, value)
+WHERE e.short_url IS NOT NULL AND e.event_time >= b.since
+GROUP BY 1, 2, 3, 4, 5, 6;
+
+DROP TABLE rebuilt_buckets;
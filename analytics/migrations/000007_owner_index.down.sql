@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_url_created_events_user_id;
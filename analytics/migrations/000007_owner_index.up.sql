@@ -0,0 +1,2 @@
+-- Exports by owner select the links a user created.
+CREATE INDEX IF NOT EXISTS idx_url_created_events_user_id ON url_created_events(user_id);
@@ -0,0 +1,9 @@
+DELETE FROM url_visit_rollups WHERE dimension IN ('source', 'utm_campaign');
+
+ALTER TABLE url_visited_events
+    DROP COLUMN IF EXISTS referrer_host,
+    DROP COLUMN IF EXISTS referrer_path,
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS utm_source,
+    DROP COLUMN IF EXISTS utm_medium,
+    DROP COLUMN IF EXISTS utm_campaign;
@@ -0,0 +1,29 @@
+-- Producers parse the referrer and classify where each visit came from, and
+-- pass on the UTM parameters of the short link request.
+ALTER TABLE url_visited_events
+    ADD COLUMN referrer_host TEXT,
+    ADD COLUMN referrer_path TEXT,
+    ADD COLUMN source TEXT,
+    ADD COLUMN utm_source TEXT,
+    ADD COLUMN utm_medium TEXT,
+    ADD COLUMN utm_campaign TEXT;
+
+-- Older visits get their host, and a source only when they were direct:
+-- telling search from social needs the producer's host lists. Paths are not
+-- recovered, the referer may already be anonymized.
+UPDATE url_visited_events SET
+    referrer_host = NULLIF(referer_host(referer), ''),
+    source = CASE WHEN COALESCE(referer, '') = '' THEN 'direct' END;
+
+-- Add the new rollup dimensions for the visits still stored. Their existing
+-- rollups are left as they are.
+INSERT INTO url_visit_rollups (granularity, bucket, short_url, dimension, value, is_bot, visits)
+SELECT g.granularity, date_trunc(g.granularity, e.event_time, 'UTC'), e.short_url, d.dimension, d.value, e.is_bot, count(*)
+FROM url_visited_events e
+CROSS JOIN (VALUES ('hour'), ('day')) AS g(granularity)
+CROSS JOIN LATERAL (VALUES
+    ('source', COALESCE(e.source, '')),
+    ('utm_campaign', COALESCE(e.utm_campaign, ''))
+) AS d(dimension, value)
+WHERE e.short_url IS NOT NULL AND e.event_time IS NOT NULL
+GROUP BY 1, 2, 3, 4, 5, 6;
@@ -0,0 +1,28 @@
+-- Redelivered visits share the event_id and collapse when parts merge.
+-- Monthly partitions keep the expiry mutations of retention to the parts of
+-- the expired days.
+CREATE TABLE IF NOT EXISTS url_visited_events (
+    event_id String,
+    short_url String,
+    event_time DateTime64(6, 'UTC'),
+    user_id Nullable(String),
+    referer String,
+    ip_address Nullable(String),
+    user_agent Nullable(String),
+    country LowCardinality(String),
+    region String,
+    city String,
+    browser LowCardinality(String),
+    os LowCardinality(String),
+    device_type LowCardinality(String),
+    visitor_id Nullable(String),
+    is_bot Bool,
+    referrer_host String,
+    referrer_path Nullable(String),
+    source LowCardinality(String),
+    utm_source String,
+    utm_medium String,
+    utm_campaign String
+) ENGINE = ReplacingMergeTree
+PARTITION BY toYYYYMM(event_time)
+ORDER BY (short_url, event_time, event_id);
@@ -0,0 +1,7 @@
+// Package clickhouse embeds the ClickHouse schema migrations.
+package clickhouse
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/memory"
+	"analytics/internal/services/events"
+	"analytics/internal/transport/kafka"
+	"analytics/testserver"
+	"context"
+	"errors"
+	urlevents "events"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyHandler fails the first failures calls with a transient error, like a
+// database that is restarting.
+type flakyHandler struct {
+	mu       sync.Mutex
+	next     kafka.EventHandler
+	failures int
+}
+
+func (h *flakyHandler) Handle(ctx context.Context, event urlevents.UrlEvent) error {
+	h.mu.Lock()
+	fail := h.failures > 0
+	h.failures--
+	h.mu.Unlock()
+
+	if fail {
+		return errors.New("connection refused")
+	}
+	return h.next.Handle(ctx, event)
+}
+
+func TestAnalytics_DeadLetters(t *testing.T) {
+	cfg := &config.Config{
+		AppName: "analytics-e2e",
+		MsgBroker: config.MsgBroker{
+			Topic:           "url_events",
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 2 * time.Millisecond,
+		},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+
+	fixture := urlEvents(t)
+	poison := []confluent.Message{
+		{Key: []byte(alias), Value: []byte("visited e2e-link")},
+		{Key: []byte(alias), Value: []byte(`{"schema_version":2,"event_id":"x","event_type":"renamed","short_url":"e2e-link","event_time":"2025-01-01T12:00:00Z"}`)},
+		{Key: []byte(alias), Value: fixture[1].Value, Headers: []confluent.Header{{Key: urlevents.ContentTypeHeader, Value: []byte("application/x-protobuf")}}},
+	}
+	msgs := append(append([]confluent.Message{}, poison...), fixture...)
+
+	r := testserver.NewReader(cfg.MsgBroker.Topic, msgs...)
+	dlq := &testserver.DeadLetters{}
+	// The first fixture event fails twice and succeeds on the last retry.
+	handler := &flakyHandler{next: events.New(logger, repo, nil, nil), failures: 2}
+	consumer := kafka.NewConsumerFromReader(cfg, logger, r, handler, dlq)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return r.Stored() == len(msgs) }, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	dead := dlq.Messages()
+	require.Len(t, dead, len(poison))
+	for i, dl := range dead {
+		assert.Equal(t, poison[i].Value, dl.Msg.Value)
+		assert.Equal(t, 1, dl.Attempts)
+	}
+	assert.ErrorIs(t, dead[0].Cause, urlevents.ErrInvalidEvent)
+	assert.ErrorIs(t, dead[1].Cause, urlevents.ErrUnknownKind)
+	assert.ErrorIs(t, dead[2].Cause, urlevents.ErrUnknownContentType)
+
+	stats, err := repo.Stats(context.Background(), alias, false)
+	require.NoError(t, err)
+	assert.NotNil(t, stats.CreatedAt)
+	assert.EqualValues(t, 2, stats.TotalVisits)
+}
+
+func TestAnalytics_TransientFailuresRetried(t *testing.T) {
+	cfg := &config.Config{
+		AppName: "analytics-e2e",
+		MsgBroker: config.MsgBroker{
+			Topic:           "url_events",
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 2 * time.Millisecond,
+		},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+
+	fixture := urlEvents(t)[:1]
+	r := testserver.NewReader(cfg.MsgBroker.Topic, fixture...)
+	dlq := &testserver.DeadLetters{}
+	// An outage outlasting any fixed number of retries.
+	handler := &flakyHandler{next: events.New(logger, repo, nil, nil), failures: 50}
+	consumer := kafka.NewConsumerFromReader(cfg, logger, r, handler, dlq)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return r.Stored() == 1 }, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Empty(t, dlq.Messages(), "transient failures are not dead-lettered")
+	stats, err := repo.Stats(context.Background(), alias, false)
+	require.NoError(t, err)
+	assert.NotNil(t, stats.CreatedAt)
+}
+
+func TestAnalytics_ShutdownWhileRetrying(t *testing.T) {
+	cfg := &config.Config{
+		AppName: "analytics-e2e",
+		MsgBroker: config.MsgBroker{
+			Topic:           "url_events",
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 2 * time.Millisecond,
+		},
+	}
+	logger := slog.New(slog.DiscardHandler)
+
+	r := testserver.NewReader(cfg.MsgBroker.Topic, urlEvents(t)[:1]...)
+	dlq := &testserver.DeadLetters{}
+	handler := &flakyHandler{next: events.New(logger, memory.New(), nil, nil), failures: 1 << 30}
+	consumer := kafka.NewConsumerFromReader(cfg, logger, r, handler, dlq)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, consumer.Run(ctx))
+
+	// The message is neither stored nor dead-lettered, so it is delivered
+	// again after a restart.
+	assert.Zero(t, r.Stored())
+	assert.Empty(t, dlq.Messages())
+}
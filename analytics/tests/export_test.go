@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/memory"
+	"analytics/internal/services/events"
+	"analytics/internal/services/export"
+	"analytics/internal/services/stats"
+	httpserver "analytics/internal/transport/http"
+	"context"
+	urlevents "events"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalytics_ExportOwner(t *testing.T) {
+	cfg := &config.Config{
+		AppName:    "analytics-e2e",
+		HttpServer: config.HttpServer{AuthSecret: authSecret},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+	handler := events.New(logger, repo, nil, nil)
+
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	owned := urlevents.New(urlevents.KindCreated, "owned-link", at)
+	owned.OriginalURL = "https://example.com/owned"
+	owned.UserID = "alice"
+	require.NoError(t, handler.Handle(context.Background(), owned))
+
+	visit := urlevents.New(urlevents.KindVisited, "owned-link", at.Add(time.Minute))
+	visit.Visit = urlevents.Visit{Country: "Germany"}
+	require.NoError(t, handler.Handle(context.Background(), visit))
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repo, nil), nil, export.New(repo)).Handler())
+	t.Cleanup(srv.Close)
+
+	get := func(query url.Values, header http.Header) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/export?"+query.Encode(), nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	byOwner := url.Values{"owner": {"alice"}, "columns": {"short_url,country"}}
+	byLink := url.Values{"short_url": {"owned-link"}, "columns": {"short_url,country"}}
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		header http.Header
+		want   int
+	}{
+		{"owner without token", byOwner, nil, http.StatusForbidden},
+		{"owner with forged header", byOwner, http.Header{"X-User-Id": {"alice"}}, http.StatusForbidden},
+		{"owner with token of another user", byOwner, bearer(t, authSecret, "bob", time.Hour), http.StatusForbidden},
+		{"owner with token of another secret", byOwner, bearer(t, "not-the-secret", "alice", time.Hour), http.StatusForbidden},
+		{"owner", byOwner, bearer(t, authSecret, "alice", time.Hour), http.StatusOK},
+		{"link with forged header", byLink, http.Header{"X-User-Id": {"alice"}}, http.StatusForbidden},
+		{"link with token of another user", byLink, bearer(t, authSecret, "bob", time.Hour), http.StatusForbidden},
+		{"link", byLink, bearer(t, authSecret, "alice", time.Hour), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(tt.query, tt.header)
+			assert.Equal(t, tt.want, status)
+			if tt.want == http.StatusOK {
+				assert.Equal(t, "short_url,country\nowned-link,Germany\n", body)
+			}
+		})
+	}
+}
+
+func TestAnalytics_ExportReusedAlias(t *testing.T) {
+	cfg := &config.Config{
+		AppName:    "analytics-e2e",
+		HttpServer: config.HttpServer{AuthSecret: authSecret},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+	handler := events.New(logger, repo, nil, nil)
+
+	// alice deletes her link and bob creates one under the same alias.
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []struct {
+		kind    urlevents.Kind
+		owner   string
+		country string
+	}{
+		{urlevents.KindCreated, "alice", ""},
+		{urlevents.KindVisited, "", "France"},
+		{urlevents.KindDeleted, "", ""},
+		{urlevents.KindCreated, "bob", ""},
+		{urlevents.KindVisited, "", "Spain"},
+	} {
+		ev := urlevents.New(e.kind, "reused-link", at.Add(time.Duration(i)*time.Minute))
+		ev.OriginalURL = "https://example.com/" + e.owner
+		ev.UserID = e.owner
+		ev.Visit.Country = e.country
+		require.NoError(t, handler.Handle(context.Background(), ev))
+	}
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repo, nil), nil, export.New(repo)).Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		user  string
+		want  string
+	}{
+		{"first owner", url.Values{"owner": {"alice"}}, "alice", "reused-link,France\n"},
+		{"second owner", url.Values{"owner": {"bob"}}, "bob", "reused-link,Spain\n"},
+		{"link of the second owner", url.Values{"short_url": {"reused-link"}}, "bob", "reused-link,Spain\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Set("columns", "short_url,country")
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/export?"+tt.query.Encode(), nil)
+			require.NoError(t, err)
+			req.Header = bearer(t, authSecret, tt.user, time.Hour)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "short_url,country\n"+tt.want, string(body))
+		})
+	}
+}
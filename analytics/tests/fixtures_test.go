@@ -0,0 +1,69 @@
+package tests
+
+import (
+	urlevents "events"
+	"net/http"
+	"testing"
+	"time"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const alias = "e2e-link"
+
+// authSecret signs the bearer tokens of the tests.
+const authSecret = "test-secret"
+
+// bearer returns the Authorization header of a token issued to user that
+// expires after ttl, signed with secret.
+func bearer(t *testing.T, secret, user string, ttl time.Duration) http.Header {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   user,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// urlEvents returns the messages url-shortener produces for a link that is
+// created and then followed from a search, from a newsletter and by a link
+// preview bot. What url-shortener really sends is checked by the e2e module.
+func urlEvents(t *testing.T) []confluent.Message {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	created := urlevents.New(urlevents.KindCreated, alias, at)
+	created.OriginalURL = "https://example.com/e2e"
+
+	visit := func(minute int, v urlevents.Visit) urlevents.UrlEvent {
+		e := urlevents.New(urlevents.KindVisited, alias, at.Add(time.Duration(minute)*time.Minute))
+		e.OriginalURL = created.OriginalURL
+		e.Visit = v
+		return e
+	}
+
+	var msgs []confluent.Message
+	for _, e := range []urlevents.UrlEvent{
+		created,
+		visit(1, urlevents.Visit{
+			IPAddress: "203.0.113.10", Country: "Germany", Browser: "Chrome", OS: "Windows 10", DeviceType: "Desktop",
+			Referrer: "https://www.google.com/", ReferrerHost: "www.google.com", ReferrerPath: "/", Source: urlevents.SourceSearch,
+		}),
+		visit(2, urlevents.Visit{
+			IPAddress: "198.51.100.7", Country: "United States", Browser: "Safari", DeviceType: "Mobile",
+			Source: urlevents.SourceEmail, UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "spring",
+		}),
+		visit(3, urlevents.Visit{
+			IPAddress: "198.51.100.7", Country: "United States", Browser: "Slackbot-LinkExpanding", DeviceType: "Bot",
+			IsBot: true, Source: urlevents.SourceDirect,
+		}),
+	} {
+		value, err := urlevents.Encode(e)
+		require.NoError(t, err)
+		msgs = append(msgs, confluent.Message{Key: []byte(alias), Value: value})
+	}
+
+	return msgs
+}
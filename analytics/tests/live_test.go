@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/memory"
+	"analytics/internal/services/events"
+	"analytics/internal/services/live"
+	"analytics/internal/services/stats"
+	httpserver "analytics/internal/transport/http"
+	"analytics/internal/transport/kafka"
+	"analytics/testserver"
+	"bufio"
+	"context"
+	"encoding/json"
+	urlevents "events"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalytics_Live(t *testing.T) {
+	cfg := &config.Config{
+		AppName:    "analytics-e2e",
+		MsgBroker:  config.MsgBroker{Topic: "url_events"},
+		HttpServer: config.HttpServer{AuthSecret: authSecret},
+		Live:       config.Live{Buffer: 8, KeepAlive: time.Minute},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+	hub := live.NewHub(cfg.Live.Buffer)
+	handler := events.New(logger, repo, nil, hub)
+
+	fixture := urlEvents(t)
+	created, err := urlevents.Decode(fixture[0].Value)
+	require.NoError(t, err)
+	require.NoError(t, handler.Handle(context.Background(), created))
+
+	owned := urlevents.New(urlevents.KindCreated, "owned-link", time.Now())
+	owned.OriginalURL = "https://example.com/owned"
+	owned.UserID = "alice"
+	require.NoError(t, handler.Handle(context.Background(), owned))
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repo, nil), hub, nil).Handler())
+	t.Cleanup(srv.Close)
+
+	follow := func(shortURL string, header http.Header) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/stats/"+shortURL+"/live", nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.Equal(t, http.StatusNotFound, follow("missing", nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, follow("owned-link", nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, follow("owned-link", bearer(t, authSecret, "bob", time.Hour)).StatusCode)
+	assert.Equal(t, http.StatusOK, follow("owned-link", bearer(t, authSecret, "alice", time.Hour)).StatusCode)
+
+	// Links created anonymously have no owner to restrict them to.
+	stream := follow(alias, nil)
+	require.Equal(t, http.StatusOK, stream.StatusCode)
+	require.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+	// The response headers are sent once subscribed, so the visits consumed
+	// from here on reach the stream.
+	r := testserver.NewReader(cfg.MsgBroker.Topic, fixture[1:]...)
+	consumer := kafka.NewConsumerFromReader(cfg, logger, r, handler, &testserver.DeadLetters{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	visits := make(chan live.Visit)
+	go func() {
+		defer close(visits)
+
+		scanner := bufio.NewScanner(stream.Body)
+		event := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: ") && event == "visit":
+				var v live.Visit
+				if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &v) == nil {
+					visits <- v
+				}
+			}
+		}
+	}()
+
+	// The link preview bot is not streamed without include_bots.
+	var got []live.Visit
+	for len(got) < 2 {
+		select {
+		case v := <-visits:
+			got = append(got, v)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d visits, want 2", len(got))
+		}
+	}
+
+	assert.Equal(t, alias, got[0].ShortURL)
+	assert.Equal(t, "Germany", got[0].Country)
+	assert.Equal(t, "Desktop", got[0].DeviceType)
+	assert.Equal(t, "https://www.google.com/", got[0].Referrer)
+	assert.Equal(t, "search", got[0].Source)
+	assert.Equal(t, "United States", got[1].Country)
+	assert.Equal(t, "Mobile", got[1].DeviceType)
+	assert.Equal(t, "email", got[1].Source)
+	assert.False(t, got[1].IsBot)
+}
+
+func TestAnalytics_LiveForgedCaller(t *testing.T) {
+	cfg := &config.Config{
+		AppName:    "analytics-e2e",
+		HttpServer: config.HttpServer{AuthSecret: authSecret},
+		Live:       config.Live{Buffer: 8, KeepAlive: time.Minute},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+	hub := live.NewHub(cfg.Live.Buffer)
+
+	owned := urlevents.New(urlevents.KindCreated, "owned-link", time.Now())
+	owned.OriginalURL = "https://example.com/owned"
+	owned.UserID = "alice"
+	require.NoError(t, events.New(logger, repo, nil, hub).Handle(context.Background(), owned))
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repo, nil), hub, nil).Handler())
+	t.Cleanup(srv.Close)
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	noExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString([]byte(authSecret))
+	require.NoError(t, err)
+
+	forged := []struct {
+		name   string
+		header http.Header
+	}{
+		{"user id header", http.Header{"X-User-Id": {"alice"}}},
+		{"other secret", bearer(t, "not-the-secret", "alice", time.Hour)},
+		{"expired", bearer(t, authSecret, "alice", -time.Minute)},
+		{"no expiry", http.Header{"Authorization": {"Bearer " + noExpiry}}},
+		{"unsigned", http.Header{"Authorization": {"Bearer " + unsigned}}},
+		{"not a token", http.Header{"Authorization": {"Bearer alice"}}},
+	}
+
+	for _, tt := range forged {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/stats/owned-link/live", nil)
+			require.NoError(t, err)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package testserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Reader stands in for a subscribed Kafka consumer. It hands out the queued
+// messages in order and then reports read timeouts, as an idle topic does.
+type Reader struct {
+	topic string
+
+	mu       sync.Mutex
+	messages []*kafka.Message
+	next     int
+	stored   int
+}
+
+func NewReader(topic string, messages ...kafka.Message) *Reader {
+	r := &Reader{topic: topic}
+	r.Add(messages...)
+	return r
+}
+
+// Add queues messages behind the ones already queued.
+func (r *Reader) Add(messages ...kafka.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range messages {
+		msg := messages[i]
+		msg.TopicPartition = kafka.TopicPartition{Topic: &r.topic, Offset: kafka.Offset(len(r.messages))}
+		r.messages = append(r.messages, &msg)
+	}
+}
+
+func (r *Reader) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next == len(r.messages) {
+		time.Sleep(time.Millisecond)
+		return nil, kafka.NewError(kafka.ErrTimedOut, "", false)
+	}
+
+	msg := r.messages[r.next]
+	r.next++
+	return msg, nil
+}
+
+func (r *Reader) StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stored++
+	return []kafka.TopicPartition{m.TopicPartition}, nil
+}
+
+func (r *Reader) Close() error {
+	return nil
+}
+
+// Stored returns how many messages were committed by the consumer.
+func (r *Reader) Stored() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stored
+}
+
+// Queued returns how many messages were added.
+func (r *Reader) Queued() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.messages)
+}
+
+// DeadLetters records what the consumer dead-letters instead of producing it.
+type DeadLetters struct {
+	mu       sync.Mutex
+	messages []DeadLetter
+}
+
+type DeadLetter struct {
+	Msg      *kafka.Message
+	Cause    error
+	Attempts int
+}
+
+func (d *DeadLetters) Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.messages = append(d.messages, DeadLetter{msg, cause, attempts})
+	return nil
+}
+
+func (d *DeadLetters) Messages() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]DeadLetter(nil), d.messages...)
+}
@@ -0,0 +1,74 @@
+// Package testserver runs analytics in-process for end-to-end tests that
+// drive it together with other services. Messages handed to Deliver go
+// through the real consumer into in-memory storage, and the stats API is
+// served over HTTP.
+package testserver
+
+import (
+	"analytics/internal/config"
+	"analytics/internal/repository/memory"
+	"analytics/internal/services/events"
+	"analytics/internal/services/export"
+	"analytics/internal/services/stats"
+	httpserver "analytics/internal/transport/http"
+	"analytics/internal/transport/kafka"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	confluent "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+type Server struct {
+	// URL is the base url of the HTTP API.
+	URL string
+
+	reader *Reader
+	dlq    *DeadLetters
+}
+
+// New starts a server that is stopped when t ends.
+func New(t testing.TB) *Server {
+	cfg := &config.Config{
+		AppName:   "analytics-e2e",
+		MsgBroker: config.MsgBroker{Topic: "url_events"},
+	}
+	logger := slog.New(slog.DiscardHandler)
+	repo := memory.New()
+	uniques := memory.NewUniques()
+
+	s := &Server{reader: NewReader(cfg.MsgBroker.Topic), dlq: &DeadLetters{}}
+	consumer := kafka.NewConsumerFromReader(cfg, logger, s.reader, events.New(logger, repo, uniques, nil), s.dlq)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, stats.New(cfg.Leaderboard, logger, repo, uniques), nil, export.New(repo)).Handler())
+	t.Cleanup(srv.Close)
+	s.URL = srv.URL
+
+	return s
+}
+
+// Deliver queues msgs on the url_events topic the consumer reads.
+func (s *Server) Deliver(msgs ...confluent.Message) {
+	s.reader.Add(msgs...)
+}
+
+// Settle waits until the consumer committed every delivered message.
+func (s *Server) Settle(t testing.TB) {
+	require.Eventually(t, func() bool { return s.reader.Stored() == s.reader.Queued() }, 5*time.Second, 5*time.Millisecond)
+}
+
+// DeadLetters returns the messages the consumer could not store.
+func (s *Server) DeadLetters() []DeadLetter {
+	return s.dlq.Messages()
+}
@@ -0,0 +1,286 @@
+// Package e2e runs url-shortener and analytics in one process: the events
+// url-shortener produces for the requests below are delivered to the
+// analytics consumer, and the stats it serves are checked against them.
+package e2e
+
+import (
+	"analytics/testserver"
+	"encoding/json"
+	"events"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	shortener "urlshortener/testserver"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	alias       = "e2e-link"
+	originalURL = "https://example.com/e2e"
+)
+
+// geoAPI stubs the geo lookups of url-shortener.
+func geoAPI(t *testing.T) string {
+	locations := map[string]map[string]string{
+		"/203.0.113.10": {"status": "success", "country": "Germany", "regionName": "Berlin", "city": "Berlin"},
+		"/198.51.100.7": {"status": "success", "country": "United States", "regionName": "New York", "city": "New York"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(locations[r.URL.Path])
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+// broker carries the messages of url-shortener to analytics, the way the
+// url_events topic does, and keeps the events for the assertions.
+type broker struct {
+	t       *testing.T
+	deliver func(key string, value []byte) []kafka.Message
+	to      *testserver.Server
+
+	mu     sync.Mutex
+	events []events.UrlEvent
+}
+
+func (b *broker) produce(key string, value []byte) {
+	event, err := events.Decode(value)
+	if err != nil {
+		b.t.Errorf("url-shortener produced an undecodable event: %v", err)
+		return
+	}
+
+	b.mu.Lock()
+	b.events = append(b.events, event)
+	b.mu.Unlock()
+
+	b.to.Deliver(b.deliver(key, value)...)
+}
+
+func (b *broker) produced() []events.UrlEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]events.UrlEvent(nil), b.events...)
+}
+
+func TestE2E_HappyPath(t *testing.T) {
+	deliveries := []struct {
+		name    string
+		deliver func(t *testing.T, key string, value []byte) []kafka.Message
+	}{
+		{
+			name: "json",
+			deliver: func(t *testing.T, key string, value []byte) []kafka.Message {
+				return []kafka.Message{{Key: []byte(key), Value: value}}
+			},
+		},
+		{
+			// url-shortener configured with another encoding records it in
+			// the content type header.
+			name: "avro",
+			deliver: func(t *testing.T, key string, value []byte) []kafka.Message {
+				event, err := events.Decode(value)
+				require.NoError(t, err)
+				avro, err := events.Marshal(event, events.ContentTypeAvro)
+				require.NoError(t, err)
+
+				return []kafka.Message{{
+					Key:     []byte(key),
+					Value:   avro,
+					Headers: []kafka.Header{{Key: events.ContentTypeHeader, Value: []byte(events.ContentTypeAvro)}},
+				}}
+			},
+		},
+		{
+			// A consumer restart before the offsets were committed delivers
+			// the same messages again; the stats must not change.
+			name: "redelivered",
+			deliver: func(t *testing.T, key string, value []byte) []kafka.Message {
+				msg := kafka.Message{Key: []byte(key), Value: value}
+				return []kafka.Message{msg, msg}
+			},
+		},
+	}
+
+	for _, d := range deliveries {
+		t.Run(d.name, func(t *testing.T) {
+			analytics := testserver.New(t)
+			b := &broker{
+				t:       t,
+				to:      analytics,
+				deliver: func(key string, value []byte) []kafka.Message { return d.deliver(t, key, value) },
+			}
+			tiny := shortener.New(t, shortener.Options{GeoAPIAddress: geoAPI(t), OnMessage: b.produce})
+
+			visit(t, tiny)
+			analytics.Settle(t)
+			require.Empty(t, analytics.DeadLetters())
+
+			checkStats(t, analytics.URL, b.produced())
+		})
+	}
+}
+
+// visit creates the link and follows it from a search, from a newsletter and
+// by a link preview bot.
+func visit(t *testing.T, tiny *shortener.Server) {
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  tiny.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	})
+
+	e.POST("/url").
+		WithJSON(map[string]string{"url": originalURL, "short_url": alias}).
+		Expect().
+		Status(http.StatusCreated)
+	tiny.Settle(t)
+
+	e.GET("/{alias}", alias).
+		WithHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36").
+		WithHeader("Referer", "https://www.google.com/").
+		WithHeader("X-Forwarded-For", "203.0.113.10").
+		Expect().
+		Status(http.StatusFound)
+	tiny.Settle(t)
+
+	e.GET("/{alias}", alias).
+		WithQuery("utm_source", "newsletter").
+		WithQuery("utm_medium", "email").
+		WithQuery("utm_campaign", "spring").
+		WithHeader("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1").
+		WithHeader("X-Forwarded-For", "198.51.100.7").
+		Expect().
+		Status(http.StatusFound)
+	tiny.Settle(t)
+
+	e.GET("/{alias}", alias).
+		WithHeader("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)").
+		WithHeader("X-Forwarded-For", "198.51.100.7").
+		Expect().
+		Status(http.StatusFound)
+	tiny.Settle(t)
+}
+
+// checkStats compares what analytics serves with the produced events.
+func checkStats(t *testing.T, analyticsURL string, produced []events.UrlEvent) {
+	require.Len(t, produced, 4)
+	created, visits := produced[0], produced[1:]
+	require.Equal(t, events.KindCreated, created.Kind)
+
+	e := httpexpect.Default(t, analyticsURL)
+
+	obj := e.GET("/stats/{alias}", alias).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	obj.HasValue("short_url", alias)
+	obj.HasValue("original_url", originalURL)
+	sameTime(t, created.EventTime, obj.Value("created_at"))
+	obj.HasValue("total_visits", 2)
+	obj.HasValue("unique_visitors", 2)
+	sameTime(t, visits[1].EventTime, obj.Value("last_visit_at"))
+	obj.HasValue("countries", []map[string]any{
+		{"value": "Germany", "count": 1},
+		{"value": "United States", "count": 1},
+	})
+	obj.HasValue("referrers", []map[string]any{
+		{"value": "", "count": 1},
+		{"value": "https://www.google.com/", "count": 1},
+	})
+	obj.HasValue("devices", []map[string]any{
+		{"value": "Desktop", "count": 1},
+		{"value": "Mobile", "count": 1},
+	})
+	obj.HasValue("sources", []map[string]any{
+		{"value": "email", "count": 1},
+		{"value": "search", "count": 1},
+	})
+	obj.HasValue("campaigns", []map[string]any{
+		{"value": "", "count": 1},
+		{"value": "spring", "count": 1},
+	})
+
+	// The link preview fetch is only counted on request.
+	bots := e.GET("/stats/{alias}", alias).
+		WithQuery("include_bots", "true").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	bots.HasValue("total_visits", 3)
+	sameTime(t, visits[2].EventTime, bots.Value("last_visit_at"))
+	bots.HasValue("devices", []map[string]any{
+		{"value": "Bot", "count": 1},
+		{"value": "Desktop", "count": 1},
+		{"value": "Mobile", "count": 1},
+	})
+	bots.HasValue("sources", []map[string]any{
+		{"value": "direct", "count": 1},
+		{"value": "email", "count": 1},
+		{"value": "search", "count": 1},
+	})
+
+	// The visits may straddle an hour boundary, so the expected series is
+	// built from their times.
+	var series []map[string]any
+	for _, v := range visits[:2] {
+		bucket := v.EventTime.Truncate(time.Hour).Format(time.RFC3339)
+		if n := len(series); n > 0 && series[n-1]["bucket"] == bucket {
+			series[n-1]["visits"] = series[n-1]["visits"].(int) + 1
+			series[n-1]["unique_visitors"] = series[n-1]["unique_visitors"].(int) + 1
+			continue
+		}
+		series = append(series, map[string]any{"bucket": bucket, "visits": 1, "unique_visitors": 1})
+	}
+	day := created.EventTime.Truncate(24 * time.Hour)
+	e.GET("/stats/{alias}/series", alias).
+		WithQuery("granularity", "hour").
+		WithQuery("from", day.Format(time.DateOnly)).
+		WithQuery("to", day.AddDate(0, 0, 2).Format(time.DateOnly)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().IsEqual(series)
+
+	e.GET("/stats/top").
+		WithQuery("window", "7d").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array()
+
+	// Raw exports include the bot visit, flagged.
+	want := "country,device_type,browser,is_bot\n"
+	for _, v := range visits {
+		want += fmt.Sprintf("%s,%s,%s,%t\n", v.Visit.Country, v.Visit.DeviceType, v.Visit.Browser, v.Visit.IsBot)
+	}
+	exported := e.GET("/export").
+		WithQuery("short_url", alias).
+		WithQuery("columns", "country,device_type,browser,is_bot").
+		Expect().
+		Status(http.StatusOK)
+	exported.Header("Content-Type").IsEqual("text/csv")
+	exported.Body().IsEqual(want)
+	require.True(t, strings.HasSuffix(want, "Slackbot-LinkExpanding,true\n"), "bot browser is the product name")
+}
+
+// sameTime checks a time served by analytics against the produced one. Avro
+// carries microseconds, so the nanoseconds may be cut.
+func sameTime(t *testing.T, want time.Time, got *httpexpect.Value) {
+	served, err := time.Parse(time.RFC3339Nano, got.String().Raw())
+	require.NoError(t, err)
+	require.WithinDuration(t, want, served, time.Microsecond)
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+)
+
+// avroMagic starts every Avro single-object encoded payload. It is followed
+// by the little-endian CRC-64-AVRO fingerprint of the writer schema.
+var avroMagic = []byte{0xC3, 0x01}
+
+const avroHeaderLen = 10
+
+var (
+	//go:embed schemas/url_event.v2.avsc
+	avroSchemaV2 string
+	//go:embed schemas/url_event.v2.1.avsc
+	avroSchemaV2_1 string
+	//go:embed schemas/url_event.v2.2.avsc
+	avroSchemaV2_2 string
+	//go:embed schemas/url_event.v2.3.avsc
+	avroSchemaV2_3 string
+)
+
+// avroSchema is the schema events are written with.
+var (
+	avroSchema      = avro.MustParse(avroSchemaV2_3)
+	avroFingerprint = mustFingerprint(avroSchema)
+)
+
+// avroSchemas holds the writer schemas Unmarshal accepts, by fingerprint.
+// When the schema changes, the previous one stays here so events already in
+// the topic can still be read.
+var avroSchemas = registerSchemas(avroSchemaV2, avroSchemaV2_1, avroSchemaV2_2, avroSchemaV2_3)
+
+func registerSchemas(sources ...string) map[string]avro.Schema {
+	schemas := make(map[string]avro.Schema, len(sources))
+	for _, src := range sources {
+		s := avro.MustParse(src)
+		schemas[string(mustFingerprint(s))] = s
+	}
+	return schemas
+}
+
+var errUnknownFingerprint = errors.New("unknown avro schema fingerprint")
+
+func mustFingerprint(s avro.Schema) []byte {
+	fp, err := s.FingerprintUsing(avro.CRC64AvroLE)
+	if err != nil {
+		panic(err)
+	}
+	return fp
+}
+
+func encodeAvro(e UrlEvent) ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	payload, err := avro.Marshal(avroSchema, e)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, avroHeaderLen+len(payload))
+	out = append(out, avroMagic...)
+	out = append(out, avroFingerprint...)
+
+	return append(out, payload...), nil
+}
+
+func decodeAvro(data []byte) (UrlEvent, error) {
+	if len(data) < avroHeaderLen || !bytes.HasPrefix(data, avroMagic) {
+		return UrlEvent{}, fmt.Errorf("%w: not an avro single-object payload", ErrInvalidEvent)
+	}
+
+	fingerprint := data[2:avroHeaderLen]
+	writer, ok := avroSchemas[string(fingerprint)]
+	if !ok {
+		return UrlEvent{}, fmt.Errorf("%w: %w", ErrInvalidEvent, errUnknownFingerprint)
+	}
+
+	schema := avroSchema
+	if !bytes.Equal(fingerprint, avroFingerprint) {
+		resolved, err := avro.NewSchemaCompatibility().Resolve(avroSchema, writer)
+		if err != nil {
+			return UrlEvent{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+		}
+		schema = resolved
+	}
+
+	var e UrlEvent
+	if err := avro.Unmarshal(schema, data[avroHeaderLen:], &e); err != nil {
+		return UrlEvent{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	e.EventTime = e.EventTime.UTC()
+
+	if err := e.Validate(); err != nil {
+		return UrlEvent{}, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ContentTypeHeader is the Kafka header that tells consumers how the message
+// value is encoded. Messages without it are JSON.
+const ContentTypeHeader = "content-type"
+
+const (
+	ContentTypeJSON = "application/json"
+	// ContentTypeAvro is Avro single-object encoding of the latest schema in
+	// schemas/, currently url_event.v2.3.avsc.
+	ContentTypeAvro = "application/avro"
+)
+
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// ValidContentType reports whether Marshal and Unmarshal support ct.
+func ValidContentType(ct string) bool {
+	switch ct {
+	case "", ContentTypeJSON, ContentTypeAvro:
+		return true
+	}
+	return false
+}
+
+// Marshal encodes e as contentType. An empty content type is JSON.
+func Marshal(e UrlEvent, contentType string) ([]byte, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return Encode(e)
+	case ContentTypeAvro:
+		return encodeAvro(e)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+}
+
+// Unmarshal decodes a payload written by Marshal with the same content type.
+func Unmarshal(data []byte, contentType string) (UrlEvent, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return Decode(data)
+	case ContentTypeAvro:
+		return decodeAvro(data)
+	}
+	return UrlEvent{}, fmt.Errorf("%w: %q", ErrUnknownContentType, contentType)
+}
+
+// legacyNamespace seeds the IDs derived for version 1 events.
+var legacyNamespace = uuid.MustParse("6f1c1c56-5f43-4e53-9a55-8d1f7c2b0e0a")
+
+// Encode validates e and returns its JSON form.
+func Encode(e UrlEvent) ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(e)
+}
+
+// Decode parses an event of any supported version and upgrades it to the
+// current one.
+func Decode(data []byte) (UrlEvent, error) {
+	var envelope struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return UrlEvent{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+
+	var (
+		e   UrlEvent
+		err error
+	)
+	switch envelope.SchemaVersion {
+	case 0, 1:
+		e, err = decodeV1(data)
+	case SchemaVersion:
+		err = json.Unmarshal(data, &e)
+	default:
+		return UrlEvent{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, envelope.SchemaVersion)
+	}
+	if err != nil {
+		return UrlEvent{}, fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+
+	if err := e.Validate(); err != nil {
+		return UrlEvent{}, err
+	}
+
+	return e, nil
+}
+
+// decodeV1 upgrades the original payload. It had no event ID, so one is
+// derived from the payload itself: a redelivered message gets the same ID.
+func decodeV1(data []byte) (UrlEvent, error) {
+	var v1 struct {
+		UrlEvent
+		Visit
+	}
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return UrlEvent{}, err
+	}
+
+	e := v1.UrlEvent
+	e.SchemaVersion = SchemaVersion
+	e.EventID = uuid.NewSHA1(legacyNamespace, data).String()
+	e.Visit = v1.Visit
+
+	return e, nil
+}
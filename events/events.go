@@ -0,0 +1,136 @@
+// Package events defines the url_events contract shared by url-shortener,
+// which produces the events, and analytics, which consumes them.
+//
+// Evolution rules:
+//
+//   - Adding an optional field is backward compatible and does not change
+//     SchemaVersion. Decoders ignore fields they do not know.
+//   - Removing, renaming or changing the meaning of a field bumps
+//     SchemaVersion, and Decode learns to upgrade the previous shape.
+//   - Decode rejects versions newer than SchemaVersion, so consumers are
+//     deployed before the producers that emit a new version.
+//
+// Events travel as JSON or as Avro (schemas/url_event.v*.avsc). Producers
+// name the encoding in the ContentTypeHeader header; a missing header means
+// JSON. A schema change adds a new .avsc next to the old one, which stays
+// registered so older messages remain readable. Optional fields bump the
+// minor number of the file, as in url_event.v2.1.avsc.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Topic is the Kafka topic url events are published to.
+const Topic = "url_events"
+
+// SchemaVersion is the version written by this package. Version 1 is the
+// original payload, which had no schema_version or event_id and carried the
+// visit details at the top level.
+const SchemaVersion = 2
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+	ErrUnknownKind        = errors.New("unknown event kind")
+	ErrInvalidEvent       = errors.New("invalid event")
+)
+
+type Kind string
+
+const (
+	KindCreated Kind = "created"
+	KindVisited Kind = "visited"
+	KindDeleted Kind = "deleted"
+)
+
+func (k Kind) Valid() bool {
+	switch k {
+	case KindCreated, KindVisited, KindDeleted:
+		return true
+	}
+	return false
+}
+
+type UrlEvent struct {
+	SchemaVersion int       `json:"schema_version" avro:"schema_version"`
+	EventID       string    `json:"event_id" avro:"event_id"`
+	Kind          Kind      `json:"event_type" avro:"event_type"`
+	ShortURL      string    `json:"short_url" avro:"short_url"`
+	OriginalURL   string    `json:"original_url,omitempty" avro:"original_url"`
+	UserID        string    `json:"user_id" avro:"user_id"`
+	EventTime     time.Time `json:"event_time" avro:"event_time"`
+	// Visit is only set on visited events.
+	Visit Visit `json:"visit,omitzero" avro:"visit"`
+}
+
+// Visit describes the request that followed a short url.
+type Visit struct {
+	IPAddress  string `json:"ip_address,omitempty" avro:"ip_address"`
+	UserAgent  string `json:"user_agent,omitempty" avro:"user_agent"`
+	Referrer   string `json:"referrer,omitempty" avro:"referrer"`
+	Country    string `json:"country,omitempty" avro:"country"`
+	Region     string `json:"region,omitempty" avro:"region"`
+	City       string `json:"city,omitempty" avro:"city"`
+	Browser    string `json:"browser,omitempty" avro:"browser"`
+	OS         string `json:"os,omitempty" avro:"os"`
+	DeviceType string `json:"device_type,omitempty" avro:"device_type"`
+	// VisitorID is a pseudonymous visitor key that rotates daily, for
+	// counting unique visitors without the IP address. Optional.
+	VisitorID string `json:"visitor_id,omitempty" avro:"visitor_id"`
+	// IsBot marks visits from crawlers, link-preview fetchers and other
+	// automated clients.
+	IsBot bool `json:"is_bot,omitempty" avro:"is_bot"`
+	// ReferrerHost and ReferrerPath are parsed from Referrer. The query is
+	// left out, since it often holds search terms.
+	ReferrerHost string `json:"referrer_host,omitempty" avro:"referrer_host"`
+	ReferrerPath string `json:"referrer_path,omitempty" avro:"referrer_path"`
+	// Source is the kind of traffic the visit came from, one of the Source
+	// constants. Empty for visits recorded before it was classified.
+	Source string `json:"source,omitempty" avro:"source"`
+	// The UTM parameters of the short link request.
+	UTMSource   string `json:"utm_source,omitempty" avro:"utm_source"`
+	UTMMedium   string `json:"utm_medium,omitempty" avro:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign,omitempty" avro:"utm_campaign"`
+}
+
+// Traffic sources of a visit.
+const (
+	SourceDirect   = "direct"
+	SourceSearch   = "search"
+	SourceSocial   = "social"
+	SourceEmail    = "email"
+	SourceInternal = "internal"
+	// SourceReferral is any other website.
+	SourceReferral = "referral"
+)
+
+// New returns an event of the current schema version with a fresh event ID.
+// IDs are UUIDv7, so they sort by creation time.
+func New(kind Kind, shortURL string, at time.Time) UrlEvent {
+	return UrlEvent{
+		SchemaVersion: SchemaVersion,
+		EventID:       uuid.Must(uuid.NewV7()).String(),
+		Kind:          kind,
+		ShortURL:      shortURL,
+		EventTime:     at.UTC(),
+	}
+}
+
+// Validate reports whether e can be published or stored.
+func (e UrlEvent) Validate() error {
+	switch {
+	case !e.Kind.Valid():
+		return fmt.Errorf("%w: %q", ErrUnknownKind, e.Kind)
+	case e.EventID == "":
+		return fmt.Errorf("%w: missing event_id", ErrInvalidEvent)
+	case e.ShortURL == "":
+		return fmt.Errorf("%w: missing short_url", ErrInvalidEvent)
+	case e.EventTime.IsZero():
+		return fmt.Errorf("%w: missing event_time", ErrInvalidEvent)
+	}
+	return nil
+}
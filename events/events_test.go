@@ -0,0 +1,177 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	e := New(KindVisited, "abc", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	e.OriginalURL = "https://example.com"
+	e.Visit = Visit{IPAddress: "203.0.113.10", Country: "Germany", DeviceType: "Desktop"}
+
+	data, err := Encode(e)
+	require.NoError(t, err)
+
+	got, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, e, got)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    UrlEvent
+		wantErr error
+	}{
+		{
+			name: "version 1 visit",
+			payload: `{"event_type":"visited","short_url":"abc","original_url":"https://example.com","user_id":"",` +
+				`"event_time":"2025-01-01T12:00:00Z","ip_address":"203.0.113.10","country":"Germany","device_type":"Desktop"}`,
+			want: UrlEvent{
+				SchemaVersion: SchemaVersion,
+				EventID:       "6b790eb0-b14e-53ce-a93a-e6b1a2d89772",
+				Kind:          KindVisited,
+				ShortURL:      "abc",
+				OriginalURL:   "https://example.com",
+				EventTime:     time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+				Visit:         Visit{IPAddress: "203.0.113.10", Country: "Germany", DeviceType: "Desktop"},
+			},
+		},
+		{
+			name: "unknown fields are ignored",
+			payload: `{"schema_version":2,"event_id":"0194a7c0-0000-7000-8000-000000000000","event_type":"created",` +
+				`"short_url":"abc","event_time":"2025-01-01T12:00:00Z","campaign":"spring"}`,
+			want: UrlEvent{
+				SchemaVersion: SchemaVersion,
+				EventID:       "0194a7c0-0000-7000-8000-000000000000",
+				Kind:          KindCreated,
+				ShortURL:      "abc",
+				EventTime:     time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "newer version",
+			payload: `{"schema_version":3,"event_type":"created"}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "unknown kind",
+			payload: `{"schema_version":2,"event_id":"x","event_type":"renamed","short_url":"abc","event_time":"2025-01-01T12:00:00Z"}`,
+			wantErr: ErrUnknownKind,
+		},
+		{
+			name:    "not json",
+			payload: `visited abc`,
+			wantErr: ErrInvalidEvent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.payload))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeV1StableID(t *testing.T) {
+	payload := []byte(`{"event_type":"deleted","short_url":"abc","user_id":"","event_time":"2025-01-01T12:00:00Z"}`)
+
+	first, err := Decode(payload)
+	require.NoError(t, err)
+	second, err := Decode(payload)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.EventID, second.EventID, "redeliveries share an id")
+}
+
+func TestEncodeOmitsEmptyVisit(t *testing.T) {
+	data, err := Encode(New(KindCreated, "abc", time.Now()))
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.NotContains(t, fields, "visit")
+	assert.EqualValues(t, SchemaVersion, fields["schema_version"])
+}
+
+func TestMarshal(t *testing.T) {
+	e := New(KindVisited, "abc", time.Date(2025, 1, 1, 12, 0, 0, 123456789, time.UTC))
+	e.OriginalURL = "https://example.com"
+	e.Visit = Visit{
+		IPAddress: "203.0.113.10", Referrer: "https://www.google.com/search?q=tiny", Browser: "Chrome", VisitorID: "9f86d081884c7d65", IsBot: true,
+		ReferrerHost: "www.google.com", ReferrerPath: "/search", Source: SourceSearch, UTMSource: "newsletter", UTMMedium: "email", UTMCampaign: "spring",
+	}
+
+	for _, ct := range []string{"", ContentTypeJSON, ContentTypeAvro} {
+		t.Run(ct, func(t *testing.T) {
+			data, err := Marshal(e, ct)
+			require.NoError(t, err)
+
+			got, err := Unmarshal(data, ct)
+			require.NoError(t, err)
+
+			want := e
+			if ct == ContentTypeAvro {
+				want.EventTime = e.EventTime.Truncate(time.Microsecond)
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	avroPayload, err := Marshal(New(KindCreated, "abc", time.Now()), ContentTypeAvro)
+	require.NoError(t, err)
+
+	unknownSchema := append([]byte{}, avroPayload...)
+	unknownSchema[2] ^= 0xFF
+
+	tests := []struct {
+		name        string
+		payload     []byte
+		contentType string
+		wantErr     error
+	}{
+		{"unknown content type", avroPayload, "application/x-protobuf", ErrUnknownContentType},
+		{"json read as avro", []byte(`{"schema_version":2}`), ContentTypeAvro, ErrInvalidEvent},
+		{"unknown avro schema", unknownSchema, ContentTypeAvro, ErrInvalidEvent},
+		{"avro read as json", avroPayload, ContentTypeJSON, ErrInvalidEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unmarshal(tt.payload, tt.contentType)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUnmarshalPreviousAvroSchema(t *testing.T) {
+	for _, src := range []string{avroSchemaV2, avroSchemaV2_1, avroSchemaV2_2} {
+		old := avro.MustParse(src)
+		e := New(KindVisited, "abc", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+		e.Visit = Visit{IPAddress: "203.0.113.10", Country: "Germany"}
+
+		payload, err := avro.Marshal(old, e)
+		require.NoError(t, err)
+		data := append(append(append([]byte{}, avroMagic...), mustFingerprint(old)...), payload...)
+
+		got, err := Unmarshal(data, ContentTypeAvro)
+		require.NoError(t, err)
+		assert.Equal(t, e, got)
+	}
+}
@@ -0,0 +1,63 @@
+// Package seed generates deterministic synthetic data for load tests and
+// demos. Both services seed from it, so seeded with the same value they
+// agree on the generated links.
+package seed
+
+import (
+	"math/rand/v2"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+const (
+	aliasChars  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	aliasLength = 8
+)
+
+type URL struct {
+	ShortURL    string
+	OriginalURL string
+}
+
+type Generator struct {
+	faker *gofakeit.Faker
+	// rand drives the distributions. It is separate from faker so that
+	// generating events never shifts the shared URL sequence.
+	rand *rand.Rand
+}
+
+func NewGenerator(seed uint64) *Generator {
+	return &Generator{
+		faker: gofakeit.New(seed),
+		rand:  rand.New(rand.NewPCG(seed, ^seed)),
+	}
+}
+
+// URLs returns n links with unique aliases.
+func (g *Generator) URLs(n int) []URL {
+	urls := make([]URL, 0, n)
+	seen := make(map[string]struct{}, n)
+
+	for len(urls) < n {
+		alias := g.alias()
+		if _, ok := seen[alias]; ok {
+			continue
+		}
+		seen[alias] = struct{}{}
+
+		urls = append(urls, URL{
+			ShortURL:    alias,
+			OriginalURL: g.faker.URL() + "/" + g.faker.Noun(),
+		})
+	}
+
+	return urls
+}
+
+func (g *Generator) alias() string {
+	b := make([]byte, aliasLength)
+	for i := range b {
+		b[i] = aliasChars[g.faker.IntN(len(aliasChars))]
+	}
+	return string(b)
+}
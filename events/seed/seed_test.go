@@ -0,0 +1,33 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	end := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	generate := func(seed uint64) []string {
+		g := NewGenerator(seed)
+		urls := g.URLs(50)
+
+		var out []string
+		for _, e := range g.Created(urls, g.Owners(5), end.AddDate(0, 0, -30)) {
+			out = append(out, e.ShortURL+e.UserID+e.EventTime.String())
+		}
+		visits := 0
+		for e := range g.Visits(urls, 200, 30, end) {
+			assert.True(t, e.EventTime.Before(end))
+			out = append(out, e.ShortURL+e.Visit.IPAddress+e.Visit.Referrer+e.EventTime.String())
+			visits++
+		}
+		assert.Equal(t, 200, visits)
+		return out
+	}
+
+	assert.Equal(t, generate(42), generate(42))
+	assert.NotEqual(t, generate(42), generate(43))
+}
@@ -0,0 +1,206 @@
+package seed
+
+import (
+	"events"
+	"iter"
+	"math/rand/v2"
+	"time"
+)
+
+type weighted[T any] struct {
+	value  T
+	weight int
+}
+
+func pick[T any](r *rand.Rand, items []weighted[T]) T {
+	total := 0
+	for _, item := range items {
+		total += item.weight
+	}
+
+	n := r.IntN(total)
+	for _, item := range items {
+		if n < item.weight {
+			return item.value
+		}
+		n -= item.weight
+	}
+
+	return items[len(items)-1].value
+}
+
+type referrer struct {
+	url, host, source string
+}
+
+// The empty referrer is a direct visit.
+var referrers = []weighted[referrer]{
+	{referrer{"", "", events.SourceDirect}, 35},
+	{referrer{"https://www.google.com/", "www.google.com", events.SourceSearch}, 22},
+	{referrer{"https://t.co/", "t.co", events.SourceSocial}, 8},
+	{referrer{"https://www.facebook.com/", "www.facebook.com", events.SourceSocial}, 7},
+	{referrer{"https://www.linkedin.com/", "www.linkedin.com", events.SourceSocial}, 5},
+	{referrer{"https://www.reddit.com/", "www.reddit.com", events.SourceSocial}, 5},
+	{referrer{"https://mail.google.com/", "mail.google.com", events.SourceEmail}, 4},
+	{referrer{"https://news.ycombinator.com/", "news.ycombinator.com", events.SourceSocial}, 3},
+	{referrer{"https://duckduckgo.com/", "duckduckgo.com", events.SourceSearch}, 3},
+	{referrer{"https://www.bing.com/", "www.bing.com", events.SourceSearch}, 3},
+	{referrer{"https://www.instagram.com/", "www.instagram.com", events.SourceSocial}, 3},
+	{referrer{"https://outlook.live.com/", "outlook.live.com", events.SourceEmail}, 2},
+}
+
+type location struct {
+	country, region, city string
+}
+
+var locations = []weighted[location]{
+	{location{"United States", "New York", "New York"}, 12},
+	{location{"United States", "California", "San Francisco"}, 9},
+	{location{"United States", "Texas", "Austin"}, 5},
+	{location{"United States", "Illinois", "Chicago"}, 4},
+	{location{"Germany", "Berlin", "Berlin"}, 6},
+	{location{"Germany", "Bavaria", "Munich"}, 4},
+	{location{"United Kingdom", "England", "London"}, 8},
+	{location{"India", "Karnataka", "Bengaluru"}, 5},
+	{location{"India", "Maharashtra", "Mumbai"}, 4},
+	{location{"France", "Île-de-France", "Paris"}, 6},
+	{location{"Brazil", "São Paulo", "São Paulo"}, 5},
+	{location{"Canada", "Ontario", "Toronto"}, 4},
+	{location{"Japan", "Tokyo", "Tokyo"}, 4},
+	{location{"Netherlands", "North Holland", "Amsterdam"}, 3},
+	{location{"Poland", "Mazovia", "Warsaw"}, 3},
+}
+
+type client struct {
+	userAgent, browser, os, device string
+}
+
+var clients = []weighted[client]{
+	{client{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Chrome", "Windows 10", "Desktop",
+	}, 30},
+	{client{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+		"Safari", "CPU iPhone OS 17_4 like Mac OS X", "Mobile",
+	}, 20},
+	{client{
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+		"Chrome", "Android 14", "Mobile",
+	}, 18},
+	{client{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+		"Safari", "Intel Mac OS X 10_15_7", "Desktop",
+	}, 10},
+	{client{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+		"Firefox", "Windows 10", "Desktop",
+	}, 6},
+	{client{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+		"Edge", "Windows 10", "Desktop",
+	}, 6},
+	{client{
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+		"Chrome", "Linux x86_64", "Desktop",
+	}, 4},
+	{client{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Googlebot", "", "Bot",
+	}, 2},
+}
+
+// Relative traffic per hour of day (UTC), peaking in the afternoon.
+var hourWeights = []int{2, 1, 1, 1, 1, 2, 3, 5, 7, 8, 9, 9, 10, 10, 10, 9, 9, 8, 8, 7, 6, 5, 4, 3}
+
+var hours = func() []weighted[int] {
+	w := make([]weighted[int], len(hourWeights))
+	for h, weight := range hourWeights {
+		w[h] = weighted[int]{h, weight}
+	}
+	return w
+}()
+
+// Owners returns the user IDs links are attributed to.
+func (g *Generator) Owners(n int) []string {
+	owners := make([]string, n)
+	for i := range owners {
+		owners[i] = g.faker.Username()
+	}
+	return owners
+}
+
+// Created returns a created event per url, dated shortly before start and
+// owned by one of owners.
+func (g *Generator) Created(urls []URL, owners []string, start time.Time) []events.UrlEvent {
+	out := make([]events.UrlEvent, len(urls))
+	for i, u := range urls {
+		out[i] = g.event(events.KindCreated, u, start.Add(-time.Duration(g.rand.IntN(72)+1)*time.Hour))
+		if len(owners) > 0 {
+			out[i].UserID = owners[g.rand.IntN(len(owners))]
+		}
+	}
+	return out
+}
+
+// Visits yields n visit events spread over the days before end, one at a
+// time so callers can store them in batches. Link popularity follows a Zipf
+// distribution so a few links get most traffic.
+func (g *Generator) Visits(urls []URL, n int, days int, end time.Time) iter.Seq[events.UrlEvent] {
+	return func(yield func(events.UrlEvent) bool) {
+		if len(urls) == 0 || days < 1 {
+			return
+		}
+
+		zipf := rand.NewZipf(g.rand, 1.2, 1, uint64(len(urls)-1))
+		start := end.AddDate(0, 0, -days)
+
+		for range n {
+			if !yield(g.visit(urls[zipf.Uint64()], start, days)) {
+				return
+			}
+		}
+	}
+}
+
+func (g *Generator) visit(u URL, start time.Time, days int) events.UrlEvent {
+	loc := pick(g.rand, locations)
+	cl := pick(g.rand, clients)
+	ref := pick(g.rand, referrers)
+
+	eventTime := start.
+		AddDate(0, 0, g.rand.IntN(days)).
+		Add(time.Duration(pick(g.rand, hours)) * time.Hour).
+		Add(time.Duration(g.rand.IntN(3600)) * time.Second)
+
+	e := g.event(events.KindVisited, u, eventTime)
+	e.Visit = events.Visit{
+		IPAddress:  g.faker.IPv4Address(),
+		UserAgent:  cl.userAgent,
+		Referrer:   ref.url,
+		Country:    loc.country,
+		Region:     loc.region,
+		City:       loc.city,
+		Browser:    cl.browser,
+		OS:         cl.os,
+		DeviceType: cl.device,
+		IsBot:      cl.device == "Bot",
+
+		ReferrerHost: ref.host,
+		Source:       ref.source,
+	}
+	if ref.url != "" {
+		e.Visit.ReferrerPath = "/"
+	}
+
+	return e
+}
+
+// event is events.New with the ID drawn from the seeded faker, so the
+// generated events stay deterministic.
+func (g *Generator) event(kind events.Kind, u URL, at time.Time) events.UrlEvent {
+	e := events.New(kind, u.ShortURL, at)
+	e.EventID = g.faker.UUID()
+	e.OriginalURL = u.OriginalURL
+	return e
+}
@@ -0,0 +1,43 @@
+// Package kafkaheaders reads and writes Kafka message headers, for the trace
+// context and the headers the services exchange with each other.
+package kafkaheaders
+
+import "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+// Carrier adapts Kafka message headers to propagation.TextMapCarrier so
+// trace context travels with the message to consumers.
+type Carrier struct {
+	headers *[]kafka.Header
+}
+
+// NewCarrier returns a carrier reading and writing headers in place.
+func NewCarrier(headers *[]kafka.Header) Carrier {
+	return Carrier{headers: headers}
+}
+
+func (c Carrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c Carrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c Carrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// ErrUsage is returned by Run for arguments it does not understand. Callers
+// replace it with the usage line of their own binary.
+var ErrUsage = errors.New("usage: migrate up|down [N|all]|version|force VERSION")
+
+// Run is the migrate subcommand: up, down [N|all], version or force VERSION.
+// The arguments are checked before open is called, so a typo does not need
+// a database. The resulting schema version is logged.
+func Run(args []string, l *slog.Logger, open func() (*Migrator, error)) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	var apply func(m *Migrator) error
+	switch args[0] {
+	case "up":
+		apply = (*Migrator).Up
+	case "down":
+		steps := 1
+		if len(args) > 1 && args[1] == "all" {
+			steps = 0
+		} else if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		apply = func(m *Migrator) error { return m.Down(steps) }
+	case "version":
+		apply = func(*Migrator) error { return nil }
+	case "force":
+		if len(args) < 2 {
+			return ErrUsage
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		apply = func(m *Migrator) error { return m.Force(version) }
+	default:
+		return ErrUsage
+	}
+
+	m, err := open()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := apply(m); err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	l.Info("schema version", "version", version, "dirty", dirty)
+
+	return nil
+}
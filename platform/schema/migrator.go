@@ -0,0 +1,116 @@
+// Package schema applies the embedded schema migrations of the services and
+// runs their migrate subcommand.
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Migrator applies embedded schema migrations through a database specific
+// golang-migrate driver.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+func New(m *migrate.Migrate, l *slog.Logger) *Migrator {
+	m.Log = migrateLogger{l}
+	return &Migrator{m: m}
+}
+
+// NewPostgres returns a migrator for the migrations in the root of fsys. The
+// pgx driver holds a Postgres advisory lock keyed on the database and
+// migrations table for the duration of every operation, so replicas migrating
+// on boot at the same time wait for each other instead of racing.
+func NewPostgres(fsys fs.FS, dsn, database string, l *slog.Logger) (*Migrator, error) {
+	const op = "schema.NewPostgres"
+
+	source, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The driver closes the database on Close, so it gets its own pool.
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	driver, err := pgx.WithInstance(db, &pgx.Config{DatabaseName: database})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return New(m, l), nil
+}
+
+// Up applies all pending migrations. It is a no-op when the schema is
+// already current.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("schema.Migrator.Up: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back the given number of migrations, or all of them when steps
+// is not positive.
+func (m *Migrator) Down(steps int) error {
+	var err error
+	if steps > 0 {
+		err = m.m.Steps(-steps)
+	} else {
+		err = m.m.Down()
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("schema.Migrator.Down: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Force sets the schema version without running migrations, clearing the
+// dirty flag after a failed migration was fixed by hand.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+type migrateLogger struct {
+	logger *slog.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
@@ -15,6 +15,46 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/healthz": {
+            "get": {
+                "description": "reports whether the process is running, dependencies are not checked",
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "checks Postgres, Redis and Kafka and reports the status and latency of each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
         "/url": {
             "post": {
                 "consumes": [
@@ -57,6 +97,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/httpserver.Response"
                         }
                     },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.Response"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -169,6 +215,56 @@ const docTemplate = `{
                 }
             }
         },
+        "/url/{short_url}/tracking": {
+            "put": {
+                "description": "enable or disable visit tracking for a short url",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Set URL tracking",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short of the URL",
+                        "name": "short_url",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tracking",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.TrackingRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.Response"
+                        }
+                    }
+                }
+            }
+        },
         "/{short_url}": {
             "get": {
                 "consumes": [
@@ -220,6 +316,34 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "health.ComponentStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.ComponentStatus"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "httpserver.Request": {
             "type": "object",
             "required": [
@@ -240,6 +364,17 @@ const docTemplate = `{
                 "message": {}
             }
         },
+        "httpserver.TrackingRequest": {
+            "type": "object",
+            "required": [
+                "enabled"
+            ],
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
         "httpserver.UrlList": {
             "type": "object",
             "properties": {
@@ -263,20 +398,44 @@ const docTemplate = `{
                 }
             }
         },
+        "models.Clicks": {
+            "type": "object",
+            "properties": {
+                "today": {
+                    "description": "Today counts the clicks since midnight UTC.",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.URL": {
             "type": "object",
             "properties": {
+                "clicks": {
+                    "description": "Clicks is filled in by the http handlers, it is not stored with the\nlink.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Clicks"
+                        }
+                    ]
+                },
                 "created_at": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "short_url": {
+                "original_url": {
                     "type": "string"
                 },
-                "url": {
+                "short_url": {
                     "type": "string"
+                },
+                "tracking_disabled": {
+                    "description": "TrackingDisabled stops visits of the link from being published.",
+                    "type": "boolean"
                 }
             }
         }
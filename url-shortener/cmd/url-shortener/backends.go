@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"platform/schema"
+	"urlshortener/internal/config"
+	"urlshortener/internal/metrics"
+	"urlshortener/internal/repository/memory"
+	"urlshortener/internal/repository/postgres"
+	"urlshortener/internal/repository/redis"
+	"urlshortener/internal/repository/sqlite"
+	"urlshortener/internal/services/url"
+	"urlshortener/internal/transport/kafka"
+	memorybroker "urlshortener/internal/transport/memory"
+)
+
+var errDBRequired = errors.New("command requires STORAGE=db")
+
+type store interface {
+	url.URLRepository
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type cache interface {
+	url.CacheRepository
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type broker interface {
+	url.MessageBroker
+	Len() int
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+func newStore(cfg *config.Config, logger *slog.Logger) (store, error) {
+	if cfg.Storage == config.StorageMemory {
+		logger.Warn("using in-memory storage, urls are lost on restart")
+		return memory.New(), nil
+	}
+
+	logger.Info("using database storage", "driver", cfg.DB.Driver)
+	return newDBStore(cfg)
+}
+
+func newDBStore(cfg *config.Config) (store, error) {
+	if cfg.DB.Driver == config.DriverSQLite {
+		repo, err := sqlite.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		metrics.RegisterDBStats(repo.DB.DB)
+
+		return repo, nil
+	}
+
+	repo, err := postgres.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RegisterDBStats(repo.DB.DB)
+
+	return repo, nil
+}
+
+func newMigrator(cfg *config.Config, logger *slog.Logger) (*schema.Migrator, error) {
+	if cfg.DB.Driver == config.DriverSQLite {
+		return sqlite.NewMigrator(cfg, logger)
+	}
+
+	return postgres.NewMigrator(cfg, logger)
+}
+
+func newCache(cfg *config.Config, logger *slog.Logger) (cache, error) {
+	if cfg.CacheBackend == config.CacheMemory {
+		logger.Info("using in-memory cache")
+		return memory.NewCache(), nil
+	}
+
+	c, err := redis.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("redis connected", "addr", cfg.Cache.Addr)
+
+	return c, nil
+}
+
+func newBroker(cfg *config.Config, logger *slog.Logger) (broker, error) {
+	if cfg.Broker == config.BrokerMemory {
+		logger.Warn("using in-memory broker, events are not delivered to analytics")
+		return memorybroker.NewBroker(cfg.MsgBroker.MemoryLimit), nil
+	}
+
+	producer, err := kafka.NewProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("kafka producer created", "details", producer.Details())
+
+	return producer, nil
+}
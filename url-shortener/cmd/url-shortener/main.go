@@ -3,19 +3,26 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"platform/logging"
+	"platform/tracing"
 	"syscall"
 	"time"
 	"urlshortener/internal/config"
-	"urlshortener/internal/repository/postgres"
-	"urlshortener/internal/repository/redis"
+	"urlshortener/internal/lifecycle"
+	"urlshortener/internal/metrics"
+	urlrepository "urlshortener/internal/repository"
+	"urlshortener/internal/services/clicks"
+	"urlshortener/internal/services/health"
+	"urlshortener/internal/services/screener"
 	"urlshortener/internal/services/url"
+	"urlshortener/internal/services/userinfo"
 	httpserver "urlshortener/internal/transport/http"
-	"urlshortener/internal/transport/kafka"
-	"urlshortener/pkg/logging"
 
 	_ "urlshortener/api"
 
@@ -23,8 +30,8 @@ import (
 )
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
+	// .env is optional, the configuration may come from the environment alone.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		panic(err)
 	}
 
@@ -32,55 +39,176 @@ func main() {
 
 	logger := logging.SetupLogger(cfg.Env)
 
-	logger.Info("Init repository")
-	repository, err := postgres.New(cfg)
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(cfg, logger, os.Args[2:])
+		case "seed":
+			err = runSeed(cfg, logger, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			logger.Error("command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.DB.AutoMigrate && cfg.Storage == config.StorageDB {
+		logger.Info("applying migrations")
+		if err := runMigrate(cfg, logger, []string{"up"}); err != nil {
+			logger.Error("failed to apply migrations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.AppName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logger.Error("failed to init tracing", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Info("Init repository", "storage", cfg.Storage)
+	repository, err := newStore(cfg, logger)
 	if err != nil {
 		logger.Error("failed to init repository", "error", err)
 		os.Exit(1)
 	}
-	defer repository.DB.Close()
 
-	producer, err := kafka.NewProducer(cfg)
+	reportShadowedLinks(logger, repository)
+
+	producer, err := newBroker(cfg, logger)
 	if err != nil {
-		logger.Error("failed to init kafka producer", "error", err)
+		logger.Error("failed to init message broker", "error", err)
 		os.Exit(1)
 	}
-	logger.Info("kafka producer created", "details", producer.Details())
-	defer producer.Close()
+	metrics.RegisterKafkaQueueDepth(producer.Len)
 
-	cache := redis.New(cfg)
-	logger.Info("redis connected", "addr", cfg.Cache.Addr)
-	defer cache.Close()
+	cache, err := newCache(cfg, logger)
+	if err != nil {
+		logger.Error("failed to init cache", "error", err)
+		os.Exit(1)
+	}
 
-	urlService := url.New(cfg, logger, repository, producer, cache, nil)
+	urlScreener, err := screener.New(cfg, logger)
+	if err != nil {
+		logger.Error("failed to init url screener", "error", err)
+		os.Exit(1)
+	}
 
-	httpServer := httpserver.New(cfg, logger, urlService)
+	bots, err := userinfo.NewBots(cfg.Bots)
+	if err != nil {
+		logger.Error("failed to load bot lists", "error", err)
+		os.Exit(1)
+	}
+
+	urlService := url.New(cfg, logger, repository, producer, cache, userinfo.New(cfg.GeoAPIAddress, cfg.Privacy, bots, cfg.Traffic), urlScreener)
+
+	readiness := health.New(cfg.HttpServer.ReadinessTimeout)
+	readiness.Register("storage", repository.Ping)
+	readiness.Register("cache", cache.Ping)
+	readiness.Register("broker", producer.Ping)
+
+	httpServer := httpserver.New(cfg, logger, urlService, readiness)
+
+	// Components are stopped in the order they are added: stop taking
+	// traffic, wait for in-flight events, flush them, then release storage.
+	shutdown := lifecycle.New(logger)
+	shutdown.Add("http", func(ctx context.Context) error {
+		// Fail readiness first and keep serving for a while so load
+		// balancers stop sending new requests before connections close.
+		readiness.Drain()
+		logger.Info("draining server", "delay", cfg.HttpServer.DrainDelay)
+
+		select {
+		case <-time.After(cfg.HttpServer.DrainDelay):
+		case <-ctx.Done():
+		}
+
+		return httpServer.Stop(ctx)
+	})
+	if cfg.Clicks.AnalyticsAddress != "" {
+		reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+		reconciled := make(chan struct{})
+		go func() {
+			defer close(reconciled)
+			clicks.NewReconciler(cfg, logger, urlService, cache).Schedule(reconcileCtx, cfg.Clicks.ReconcileInterval)
+		}()
+		// The reconciler writes to the cache, which is closed after it.
+		shutdown.Add("clicks", func(ctx context.Context) error {
+			stopReconcile()
+			select {
+			case <-reconciled:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}
+	shutdown.Add("events", urlService.Wait)
+	shutdown.Add("broker", producer.Close)
+	shutdown.Add("screener", func(ctx context.Context) error {
+		return urlScreener.Close()
+	})
+	shutdown.Add("cache", func(ctx context.Context) error {
+		return cache.Close()
+	})
+	shutdown.Add("storage", func(ctx context.Context) error {
+		return repository.Close()
+	})
+	shutdown.Add("tracing", shutdownTracing)
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	serverErr := make(chan error, 1)
 	go func() {
 		logger.Info("server started", slog.String("address", cfg.HttpServer.Address))
 
-		if err := httpServer.Run(); err != nil {
-			if errors.Is(err, http.ErrServerClosed) {
-				logger.Warn("server shutdown")
-				return
-			}
-
-			logger.Error("failed to start server", "error", err)
+		if err := httpServer.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
-	<-done
+	select {
+	case <-done:
+	case err := <-serverErr:
+		logger.Error("failed to start server", "error", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := httpServer.Stop(ctx); err != nil {
-		logger.Error("failed to stop server", "error", err)
-		return
+	if err := shutdown.Shutdown(ctx); err != nil {
+		logger.Error("shutdown finished with errors", "error", err)
+		os.Exit(1)
 	}
 
-	logger.Info("server stoped gracefully")
+	logger.Info("server stopped gracefully")
+}
+
+// reportShadowedLinks logs the links created under an alias reserved later,
+// which can no longer be redirected.
+func reportShadowedLinks(logger *slog.Logger, repository store) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, alias := range httpserver.ReservedAliases {
+		_, err := repository.GetURL(ctx, alias)
+		switch {
+		case err == nil:
+			logger.Error("link is shadowed by a route and cannot be redirected", "short_url", alias)
+		case !errors.Is(err, urlrepository.ErrURLNotFound):
+			logger.Warn("failed to check for shadowed links", "short_url", alias, "error", err)
+			return
+		}
+	}
 }
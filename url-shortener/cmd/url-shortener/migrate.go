@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"platform/schema"
+	"urlshortener/internal/config"
+)
+
+var errMigrateUsage = errors.New("usage: url-shortener migrate up|down [N|all]|version|force VERSION")
+
+func runMigrate(cfg *config.Config, logger *slog.Logger, args []string) error {
+	if cfg.Storage != config.StorageDB {
+		return errDBRequired
+	}
+
+	err := schema.Run(args, logger, func() (*schema.Migrator, error) {
+		return newMigrator(cfg, logger)
+	})
+	if errors.Is(err, schema.ErrUsage) {
+		return errMigrateUsage
+	}
+
+	return err
+}
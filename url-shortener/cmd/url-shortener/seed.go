@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"events/seed"
+	"flag"
+	"log/slog"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository"
+)
+
+func runSeed(cfg *config.Config, logger *slog.Logger, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	urls := fs.Int("urls", 1000, "number of urls to generate")
+	seedValue := fs.Uint64("seed", 1, "random seed, the same seed produces the same data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Storage != config.StorageDB {
+		return errDBRequired
+	}
+
+	repo, err := newDBStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	generator := seed.NewGenerator(*seedValue)
+
+	var created, skipped int
+	for _, u := range generator.URLs(*urls) {
+		if err := repo.SaveURL(ctx, u.OriginalURL, u.ShortURL); err != nil {
+			if !errors.Is(err, repository.ErrURLExists) {
+				return err
+			}
+			skipped++
+			continue
+		}
+		created++
+	}
+
+	logger.Info("seed finished", "seed", *seedValue, "created", created, "skipped", skipped)
+
+	return nil
+}
@@ -1,47 +1,156 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
 type Config struct {
-	AppName     string `envconfig:"NAME" required:"true"`
-	Env         string `envconfig:"ENV" default:"prod"`
-	Debug       bool   `envconfig:"DEBUG" default:"false"`
-	AliasLength int    `envconfig:"ALIAS_LENGTH" default:"6"`
-	HttpServer  HttpServer
-	DB          DB
-	MsgBroker   MsgBroker
-	Cache       Cache
+	AppName         string        `envconfig:"NAME" required:"true"`
+	Env             string        `envconfig:"ENV" default:"prod"`
+	Debug           bool          `envconfig:"DEBUG" default:"false"`
+	AliasLength     int           `envconfig:"ALIAS_LENGTH" default:"6"`
+	GeoAPIAddress   string        `envconfig:"GEO_API_ADDRESS" default:"http://ip-api.com/json"`
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	Storage         string        `envconfig:"STORAGE" default:"db"`
+	CacheBackend    string        `envconfig:"CACHE" default:"redis"`
+	Broker          string        `envconfig:"BROKER" default:"kafka"`
+	HttpServer      HttpServer
+	DB              DB
+	MsgBroker       MsgBroker
+	Cache           Cache
+	Screener        Screener
+	Privacy         Privacy
+	Bots            Bots
+	Traffic         Traffic
+	Clicks          Clicks
+	Tracing         Tracing
 }
 
+const (
+	StorageDB     = "db"
+	StorageMemory = "memory"
+
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	CacheRedis  = "redis"
+	CacheMemory = "memory"
+
+	BrokerKafka  = "kafka"
+	BrokerMemory = "memory"
+
+	EncodingJSON = "json"
+	EncodingAvro = "avro"
+
+	IPModeFull     = "full"
+	IPModeTruncate = "truncate"
+	IPModeHash     = "hash"
+)
+
 type HttpServer struct {
-	Address     string        `envconfig:"HTTP_ADDRESS" required:"true"`
-	TimeOut     time.Duration `envconfig:"HTTP_TIMEOUT"`
-	IdleTimeout time.Duration `envconfig:"HTTP_IDLE_TIMEOUT"`
-	User        string        `envconfig:"BASIC_AUTH_USER"`
-	Password    string        `envconfig:"BASIC_AUTH_PASSWORD"`
+	Address          string        `envconfig:"HTTP_ADDRESS" required:"true"`
+	TimeOut          time.Duration `envconfig:"HTTP_TIMEOUT"`
+	IdleTimeout      time.Duration `envconfig:"HTTP_IDLE_TIMEOUT"`
+	DrainDelay       time.Duration `envconfig:"HTTP_DRAIN_DELAY" default:"5s"`
+	ReadinessTimeout time.Duration `envconfig:"HTTP_READINESS_TIMEOUT" default:"2s"`
+	User             string        `envconfig:"BASIC_AUTH_USER"`
+	Password         string        `envconfig:"BASIC_AUTH_PASSWORD"`
 }
 
 type DB struct {
-	Host     string `envconfig:"POSTGRES_HOST" required:"true"`
-	Port     string `envconfig:"POSTGRES_PORT" required:"true"`
-	Name     string `envconfig:"POSTGRES_DB" required:"true"`
-	Username string `envconfig:"POSTGRES_USER" required:"true"`
-	Password string `envconfig:"POSTGRES_PASSWORD"`
+	Driver      string `envconfig:"DB_DRIVER" default:"postgres"`
+	Path        string `envconfig:"SQLITE_PATH" default:"tiny.db"`
+	Host        string `envconfig:"POSTGRES_HOST"`
+	Port        string `envconfig:"POSTGRES_PORT"`
+	Name        string `envconfig:"POSTGRES_DB"`
+	Username    string `envconfig:"POSTGRES_USER"`
+	Password    string `envconfig:"POSTGRES_PASSWORD"`
+	AutoMigrate bool   `envconfig:"DB_AUTO_MIGRATE" default:"false"`
 }
 
 type MsgBroker struct {
-	Addr         []string `envconfig:"KAFKA_ADDRESS" required:"true"`
+	Addr         []string `envconfig:"KAFKA_ADDRESS"`
 	FlushTimeout int      `envconfig:"KAFKA_PRODUCER_FLUSH_TIME" default:"5000"`
+	// Encoding is the wire format of produced events, EncodingJSON or
+	// EncodingAvro. It is recorded in a header, so it can be switched without
+	// coordinating with consumers.
+	Encoding string `envconfig:"KAFKA_EVENT_ENCODING" default:"json"`
+	// MemoryLimit bounds the messages the in-memory broker keeps per topic.
+	// Nothing consumes them, the oldest are dropped.
+	MemoryLimit int `envconfig:"BROKER_MEMORY_LIMIT" default:"1000"`
 }
 
 type Cache struct {
-	Addr     string `envconfig:"REDIS_ADDRESS"`
-	Password string `envconfig:"REDIS_PASSWORD"`
-	Db       int    `envconfig:"REDIS_DB"`
+	Addr     string        `envconfig:"REDIS_ADDRESS"`
+	Password string        `envconfig:"REDIS_PASSWORD"`
+	Db       int           `envconfig:"REDIS_DB"`
+	TTL      time.Duration `envconfig:"REDIS_TTL" default:"1h"`
+}
+
+type Screener struct {
+	ShortDomains   []string      `envconfig:"SHORT_DOMAINS"`
+	AllowedSchemes []string      `envconfig:"SCREENER_ALLOWED_SCHEMES" default:"http,https"`
+	BlocklistPath  string        `envconfig:"SCREENER_BLOCKLIST_PATH"`
+	ResolveHosts   bool          `envconfig:"SCREENER_RESOLVE_HOSTS" default:"true"`
+	ResolveTimeout time.Duration `envconfig:"SCREENER_RESOLVE_TIMEOUT" default:"2s"`
+}
+
+// Privacy controls what visited events reveal about the visitor.
+type Privacy struct {
+	// IPMode is IPModeFull to publish the client IP, IPModeTruncate to cut
+	// IPv4 addresses to /24 and IPv6 to /48, or IPModeHash to drop the IP
+	// and publish only the visitor ID. Geo lookups use the truncated IP in
+	// both of the latter modes.
+	IPMode string `envconfig:"PRIVACY_IP_MODE" default:"full"`
+	// HashSecret keys the daily visitor ID. Without it no visitor ID is
+	// published. Required by IPModeHash.
+	HashSecret string `envconfig:"PRIVACY_HASH_SECRET"`
+	// HonorDNT drops every visitor detail from visits sent with DNT: 1 or
+	// Sec-GPC: 1. The visit itself is still counted.
+	HonorDNT bool `envconfig:"PRIVACY_HONOR_DNT" default:"true"`
+}
+
+// Bots extends the built-in list of crawlers and link-preview fetchers that
+// visits are checked against.
+type Bots struct {
+	// PatternsPath is a file of extra user agent fragments, one per line.
+	PatternsPath string `envconfig:"BOT_PATTERNS_PATH"`
+	// IPRangesPath is a file of CIDR ranges, one per line, such as those
+	// announced by a crawler's ASN. Text after the range is ignored.
+	IPRangesPath string `envconfig:"BOT_IP_RANGES_PATH"`
+}
+
+// Traffic configures the classification of visits by traffic source.
+type Traffic struct {
+	// InternalHosts are hosts, besides the shortener's own, whose pages
+	// count as internal traffic, such as the main website. Subdomains
+	// match too.
+	InternalHosts []string `envconfig:"TRAFFIC_INTERNAL_HOSTS"`
+}
+
+// Clicks configures the live click counters kept in the cache.
+type Clicks struct {
+	// DayTTL is how long the per-day counters are kept.
+	DayTTL time.Duration `envconfig:"CLICKS_DAY_TTL" default:"192h"`
+	// AnalyticsAddress is the base url of the analytics service. When set,
+	// counters are raised every ReconcileInterval to the analytics totals
+	// if they fell behind, as after a cache flush.
+	AnalyticsAddress  string        `envconfig:"ANALYTICS_ADDRESS"`
+	ReconcileInterval time.Duration `envconfig:"CLICKS_RECONCILE_INTERVAL" default:"15m"`
+	// ReconcileBatch bounds the links checked per pass. Passes continue
+	// where the previous one stopped, so every link is checked in turn.
+	ReconcileBatch int `envconfig:"CLICKS_RECONCILE_BATCH" default:"500"`
+}
+
+type Tracing struct {
+	Exporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	Endpoint    string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	Insecure    bool    `envconfig:"TRACING_OTLP_INSECURE" default:"true"`
+	SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
 }
 
 func MustLoad() *Config {
@@ -51,5 +160,68 @@ func MustLoad() *Config {
 		panic("failed to load config: " + err.Error())
 	}
 
+	if err := cfg.validate(); err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
 	return &cfg
 }
+
+// validate checks the settings of the selected backends. Connection settings
+// are only required for backends that are actually used, so the service can
+// run with in-memory storage and broker without any of them.
+func (c *Config) validate() error {
+	var errs []error
+
+	switch c.Storage {
+	case StorageDB:
+		switch c.DB.Driver {
+		case DriverPostgres:
+			if c.DB.Host == "" || c.DB.Port == "" || c.DB.Name == "" || c.DB.Username == "" {
+				errs = append(errs, errors.New("POSTGRES_HOST, POSTGRES_PORT, POSTGRES_DB and POSTGRES_USER are required for the postgres driver"))
+			}
+		case DriverSQLite:
+			if c.DB.Path == "" {
+				errs = append(errs, errors.New("SQLITE_PATH is required for the sqlite driver"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown DB_DRIVER %q", c.DB.Driver))
+		}
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown STORAGE %q", c.Storage))
+	}
+
+	switch c.CacheBackend {
+	case CacheRedis, CacheMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown CACHE %q", c.CacheBackend))
+	}
+
+	switch c.Broker {
+	case BrokerKafka:
+		if len(c.MsgBroker.Addr) == 0 {
+			errs = append(errs, errors.New("KAFKA_ADDRESS is required for kafka broker"))
+		}
+		switch c.MsgBroker.Encoding {
+		case EncodingJSON, EncodingAvro:
+		default:
+			errs = append(errs, fmt.Errorf("unknown KAFKA_EVENT_ENCODING %q", c.MsgBroker.Encoding))
+		}
+	case BrokerMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown BROKER %q", c.Broker))
+	}
+
+	switch c.Privacy.IPMode {
+	case IPModeFull, IPModeTruncate:
+	case IPModeHash:
+		if c.Privacy.HashSecret == "" {
+			errs = append(errs, errors.New("PRIVACY_HASH_SECRET is required for the hash ip mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown PRIVACY_IP_MODE %q", c.Privacy.IPMode))
+	}
+
+	return errors.Join(errs...)
+}
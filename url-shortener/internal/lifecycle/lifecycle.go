@@ -0,0 +1,53 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type StopFunc func(ctx context.Context) error
+
+type step struct {
+	name string
+	stop StopFunc
+}
+
+// Manager stops application components in a fixed order. Steps run in the
+// order they were added, so components must be registered from the edge of
+// the system (traffic) inwards (storage).
+type Manager struct {
+	logger *slog.Logger
+	steps  []step
+}
+
+func New(l *slog.Logger) *Manager {
+	return &Manager{logger: l}
+}
+
+func (m *Manager) Add(name string, stop func(ctx context.Context) error) {
+	m.steps = append(m.steps, step{name: name, stop: stop})
+}
+
+// Shutdown runs every step sequentially with the shared ctx deadline. A
+// failing step does not prevent later steps from running, so resources are
+// released even when draining times out.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	for _, s := range m.steps {
+		start := time.Now()
+
+		if err := s.stop(ctx); err != nil {
+			m.logger.Error("failed to stop component", "component", s.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+
+		m.logger.Info("component stopped", "component", s.name, "took", time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
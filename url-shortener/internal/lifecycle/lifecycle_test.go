@@ -0,0 +1,37 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	record := func(name string, err error) StopFunc {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	errFlush := errors.New("3 messages not delivered")
+
+	m := New(slogdiscard.NewDiscardLogger())
+	m.Add("http", record("http", nil))
+	m.Add("events", record("events", nil))
+	m.Add("kafka", record("kafka", errFlush))
+	m.Add("redis", record("redis", nil))
+	m.Add("postgres", record("postgres", nil))
+
+	err := m.Shutdown(context.Background())
+
+	assert.Equal(t, []string{"http", "events", "kafka", "redis", "postgres"}, order)
+	assert.ErrorIs(t, err, errFlush)
+	assert.ErrorContains(t, err, "kafka: 3 messages not delivered")
+}
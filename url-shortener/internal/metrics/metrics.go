@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of cache lookups by result (hit, miss, error).",
+	}, []string{"result"})
+
+	KafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "produced_messages_total",
+		Help:      "Number of produced messages by topic and result (success, failure).",
+	}, []string{"topic", "result"})
+
+	GeoLookupDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "geo",
+		Name:      "lookup_duration_seconds",
+		Help:      "Latency of geo IP lookups.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	GeoLookupErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "geo",
+		Name:      "lookup_errors_total",
+		Help:      "Number of failed geo IP lookups.",
+	})
+)
+
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// RegisterDBStats exposes connection pool statistics of db.
+func RegisterDBStats(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// RegisterKafkaQueueDepth exposes the number of messages waiting in the
+// producer queue, as reported by queueLen.
+func RegisterKafkaQueueDepth(queueLen func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "producer_queue_depth",
+		Help:      "Number of messages and requests waiting to be delivered to the broker.",
+	}, func() float64 {
+		return float64(queueLen())
+	})
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -7,4 +7,17 @@ type URL struct {
 	OriginalURL string    `json:"original_url" db:"original_url"`
 	ShortURL    string    `json:"short_url,omitempty" db:"short_url"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	// TrackingDisabled stops visits of the link from being published.
+	TrackingDisabled bool `json:"tracking_disabled" db:"tracking_disabled"`
+	// Clicks is filled in by the http handlers, it is not stored with the
+	// link.
+	Clicks *Clicks `json:"clicks,omitempty" db:"-"`
+}
+
+// Clicks are the live click counters of a link. Visits from bots and from
+// links with tracking disabled are not counted, like in analytics.
+type Clicks struct {
+	Total int64 `json:"total"`
+	// Today counts the clicks since midnight UTC.
+	Today int64 `json:"today"`
 }
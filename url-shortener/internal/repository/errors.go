@@ -5,4 +5,5 @@ import "errors"
 var (
 	ErrURLNotFound = errors.New("url not found")
 	ErrURLExists   = errors.New("url exists")
+	ErrCacheMiss   = errors.New("cache miss")
 )
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"urlshortener/internal/metrics"
+	"urlshortener/internal/repository"
+)
+
+type item struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (i item) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
+// Cache mirrors the redis cache: values are stored JSON encoded and a ttl
+// of zero means the key never expires. Expired keys are dropped lazily on
+// read.
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]item
+}
+
+func NewCache() *Cache {
+	return &Cache{items: make(map[string]item)}
+}
+
+func (c *Cache) Close() error {
+	return nil
+}
+
+func (c *Cache) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (c *Cache) Get(ctx context.Context, key string, target any) error {
+	c.mu.Lock()
+	it, ok := c.items[key]
+	if ok && it.expired(time.Now()) {
+		delete(c.items, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		metrics.CacheRequests.WithLabelValues(metrics.CacheMiss).Inc()
+		return repository.ErrCacheMiss
+	}
+
+	metrics.CacheRequests.WithLabelValues(metrics.CacheHit).Inc()
+	return json.Unmarshal(it.value, target)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	it := item{value: bytes}
+	if ttl > 0 {
+		it.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	c.items[key] = it
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// IncrBy adds n to the counter at key. Like redis INCRBY it works on values
+// stored with Set as long as they are integers.
+func (c *Cache) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	it, ok := c.items[key]
+	if ok && it.expired(now) {
+		ok = false
+	}
+
+	var count int64
+	if ok {
+		if err := json.Unmarshal(it.value, &count); err != nil {
+			return 0, fmt.Errorf("counter %s: %w", key, err)
+		}
+	}
+	count += n
+
+	bytes, err := json.Marshal(count)
+	if err != nil {
+		return 0, err
+	}
+
+	it.value = bytes
+	if !ok {
+		it.expiresAt = time.Time{}
+	}
+	if ttl > 0 {
+		it.expiresAt = now.Add(ttl)
+	}
+	c.items[key] = it
+
+	return count, nil
+}
+
+// GetCounts returns the counters at keys, 0 for missing ones.
+func (c *Cache) GetCounts(ctx context.Context, keys ...string) ([]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	counts := make([]int64, len(keys))
+	for i, key := range keys {
+		it, ok := c.items[key]
+		if !ok || it.expired(now) {
+			continue
+		}
+		if err := json.Unmarshal(it.value, &counts[i]); err != nil {
+			return nil, fmt.Errorf("counter %s: %w", key, err)
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,113 @@
+// Package memory implements the url repositories in process memory. It is
+// meant for tests and local development; nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+	"urlshortener/internal/models"
+	"urlshortener/internal/repository"
+)
+
+type Repository struct {
+	mu     sync.RWMutex
+	urls   map[string]models.URL
+	nextID int
+}
+
+func New() *Repository {
+	return &Repository{
+		urls:   make(map[string]models.URL),
+		nextID: 1,
+	}
+}
+
+func (r *Repository) Close() error {
+	return nil
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *Repository) GetURL(ctx context.Context, short_url string) (*models.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	url, ok := r.urls[short_url]
+	if !ok {
+		return nil, repository.ErrURLNotFound
+	}
+
+	return &url, nil
+}
+
+func (r *Repository) FetchAll(ctx context.Context) ([]*models.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	urls := make([]*models.URL, 0, len(r.urls))
+	for _, url := range r.urls {
+		urls = append(urls, &url)
+	}
+
+	slices.SortFunc(urls, func(a, b *models.URL) int {
+		return a.ID - b.ID
+	})
+
+	return urls, nil
+}
+
+func (r *Repository) SaveURL(ctx context.Context, urlToSave, short_url string) error {
+	const op = "repository.memory.SaveURL"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.urls[short_url]; ok {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLExists)
+	}
+
+	r.urls[short_url] = models.URL{
+		ID:          r.nextID,
+		OriginalURL: urlToSave,
+		ShortURL:    short_url,
+		CreatedAt:   time.Now().UTC(),
+	}
+	r.nextID++
+
+	return nil
+}
+
+func (r *Repository) DeleteURL(ctx context.Context, short_url string) error {
+	const op = "repository.memory.DeleteURL"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.urls[short_url]; !ok {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLNotFound)
+	}
+	delete(r.urls, short_url)
+
+	return nil
+}
+
+func (r *Repository) SetTracking(ctx context.Context, short_url string, enabled bool) error {
+	const op = "repository.memory.SetTracking"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[short_url]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLNotFound)
+	}
+	url.TrackingDisabled = !enabled
+	r.urls[short_url] = url
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"testing"
+	"urlshortener/internal/repository/memory"
+	"urlshortener/internal/repository/repositorytest"
+	"urlshortener/internal/services/url"
+)
+
+func TestRepository(t *testing.T) {
+	repositorytest.RunURLRepository(t, func(t *testing.T) url.URLRepository {
+		return memory.New()
+	})
+}
+
+func TestCache(t *testing.T) {
+	repositorytest.RunCacheRepository(t, func(t *testing.T) url.CacheRepository {
+		return memory.NewCache()
+	})
+}
@@ -0,0 +1,19 @@
+package postgres
+
+import (
+	"fmt"
+	"log/slog"
+	"platform/schema"
+	"urlshortener/internal/config"
+	"urlshortener/migrations"
+)
+
+// NewMigrator returns a migrator for the embedded Postgres migrations.
+func NewMigrator(cfg *config.Config, l *slog.Logger) (*schema.Migrator, error) {
+	m, err := schema.NewPostgres(migrations.FS, DSN(cfg), cfg.DB.Name, l)
+	if err != nil {
+		return nil, fmt.Errorf("repository.postgres.NewMigrator: %w", err)
+	}
+
+	return m, nil
+}
@@ -13,8 +13,14 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("urlshortener/internal/repository/postgres")
+
 type Repository struct {
 	cfg *config.Config
 	DB  *sqlx.DB
@@ -23,7 +29,16 @@ type Repository struct {
 func New(cfg *config.Config) (*Repository, error) {
 	const op = "repository.postgres.New"
 
-	dns := fmt.Sprintf(
+	db, err := sqlx.Connect("pgx", DSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Repository{cfg, db}, nil
+}
+
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
 		cfg.DB.Username,
 		cfg.DB.Password,
@@ -31,24 +46,30 @@ func New(cfg *config.Config) (*Repository, error) {
 		cfg.DB.Port,
 		cfg.DB.Name,
 	)
-	db, err := sqlx.Connect("pgx", dns)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+}
 
-	return &Repository{cfg, db}, nil
+func (r *Repository) Close() error {
+	return r.DB.Close()
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.DB.PingContext(ctx)
 }
 
 func (r *Repository) GetURL(ctx context.Context, short_url string) (*models.URL, error) {
 	const op = "repository.postgres.GetURL"
 
-	query := "SELECT id, original_url, short_url, created_at FROM url WHERE short_url=$1 LIMIT 1"
+	query := "SELECT id, original_url, short_url, created_at, tracking_disabled FROM url WHERE short_url=$1 LIMIT 1"
+
+	ctx, span := startSpan(ctx, "GetURL", query)
+	defer span.End()
 
 	url := &models.URL{}
 	if err := r.DB.GetContext(ctx, url, query, short_url); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrURLNotFound
 		}
+		recordError(span, err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -56,15 +77,16 @@ func (r *Repository) GetURL(ctx context.Context, short_url string) (*models.URL,
 }
 
 func (r *Repository) FetchAll(ctx context.Context) ([]*models.URL, error) {
-	const op = "repository.postgres.GetURL"
+	const op = "repository.postgres.FetchAll"
+
+	query := "SELECT id, short_url, original_url, created_at, tracking_disabled FROM url ORDER BY id"
 
-	query := "SELECT id, short_url, original_url, created_at FROM url"
+	ctx, span := startSpan(ctx, "FetchAll", query)
+	defer span.End()
 
 	var urls []*models.URL
 	if err := r.DB.SelectContext(ctx, &urls, query); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, repository.ErrURLNotFound
-		}
+		recordError(span, err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -76,12 +98,16 @@ func (r *Repository) SaveURL(ctx context.Context, urlToSave, short_url string) e
 
 	query := "INSERT INTO url (original_url, short_url) VALUES ($1, $2)"
 
-	_, err := r.DB.Exec(query, urlToSave, short_url)
+	ctx, span := startSpan(ctx, "SaveURL", query)
+	defer span.End()
+
+	_, err := r.DB.ExecContext(ctx, query, urlToSave, short_url)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.SQLState() == pgerrcode.UniqueViolation {
 			return fmt.Errorf("%s: %w", op, repository.ErrURLExists)
 		}
+		recordError(span, err)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -93,8 +119,12 @@ func (r *Repository) DeleteURL(ctx context.Context, short_url string) error {
 
 	query := "DELETE FROM url WHERE short_url=$1"
 
-	res, err := r.DB.ExecContext(context.Background(), query, short_url)
+	ctx, span := startSpan(ctx, "DeleteURL", query)
+	defer span.End()
+
+	res, err := r.DB.ExecContext(ctx, query, short_url)
 	if err != nil {
+		recordError(span, err)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -109,3 +139,44 @@ func (r *Repository) DeleteURL(ctx context.Context, short_url string) error {
 
 	return nil
 }
+
+func (r *Repository) SetTracking(ctx context.Context, short_url string, enabled bool) error {
+	const op = "repository.postgres.SetTracking"
+
+	query := "UPDATE url SET tracking_disabled=$1 WHERE short_url=$2"
+
+	ctx, span := startSpan(ctx, "SetTracking", query)
+	defer span.End()
+
+	res, err := r.DB.ExecContext(ctx, query, !enabled, short_url)
+	if err != nil {
+		recordError(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLNotFound)
+	}
+
+	return nil
+}
+
+func startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "postgres."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
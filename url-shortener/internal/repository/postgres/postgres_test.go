@@ -0,0 +1,45 @@
+package postgres_test
+
+import (
+	"os"
+	"testing"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository/postgres"
+	"urlshortener/internal/repository/repositorytest"
+	"urlshortener/internal/services/url"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("set TEST_POSTGRES=1 to run against postgres on localhost:5433")
+	}
+
+	cfg := &config.Config{
+		DB: config.DB{
+			Username: "test",
+			Password: "test",
+			Host:     "localhost",
+			Port:     "5433",
+			Name:     "test",
+		},
+	}
+
+	repositorytest.RunURLRepository(t, func(t *testing.T) url.URLRepository {
+		m, err := postgres.NewMigrator(cfg, slogdiscard.NewDiscardLogger())
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+		t.Cleanup(func() {
+			require.NoError(t, m.Down(0))
+			m.Close()
+		})
+
+		repo, err := postgres.New(cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+
+		return repo
+	})
+}
@@ -3,9 +3,15 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 	"urlshortener/internal/config"
+	"urlshortener/internal/metrics"
+	"urlshortener/internal/repository"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -13,27 +19,44 @@ type Cache struct {
 	client *redis.Client
 }
 
-func New(cfg *config.Config) *Cache {
+func New(cfg *config.Config) (*Cache, error) {
+	const op = "repository.redis.New"
+
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.Cache.Addr,
 		Password: cfg.Cache.Password,
 		DB:       cfg.Cache.Db,
 	})
 
-	return &Cache{client: client}
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Cache{client: client}, nil
 }
 
-func (c *Cache) Close() {
-	c.client.Close()
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
+
+func (c *Cache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
 }
 
 func (c *Cache) Get(ctx context.Context, key string, target any) error {
 	bytes, err := c.client.Get(ctx, key).Bytes()
-	if err == redis.Nil {
-		return nil
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			metrics.CacheRequests.WithLabelValues(metrics.CacheMiss).Inc()
+			return repository.ErrCacheMiss
+		}
+		metrics.CacheRequests.WithLabelValues(metrics.CacheError).Inc()
+		return err
 	}
 
-	return json.Unmarshal(bytes, &target)
+	metrics.CacheRequests.WithLabelValues(metrics.CacheHit).Inc()
+	return json.Unmarshal(bytes, target)
 }
 
 func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
@@ -45,6 +68,54 @@ func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duratio
 	return c.client.Set(ctx, key, bytes, ttl).Err()
 }
 
-func (c *Cache) Delete(ctx context.Context, key string) error {
-	return c.client.Del(ctx, key).Err()
+// Delete removes keys. Missing keys are not an error.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// IncrBy adds n to the counter at key and returns the new value. A ttl above
+// zero (re)sets the expiry of the key.
+func (c *Cache) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		incr = p.IncrBy(ctx, key, n)
+		if ttl > 0 {
+			p.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+// GetCounts returns the counters at keys, 0 for missing ones.
+func (c *Cache) GetCounts(ctx context.Context, keys ...string) ([]int64, error) {
+	counts := make([]int64, len(keys))
+	if len(keys) == 0 {
+		return counts, nil
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if counts[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("counter %s: %w", keys[i], err)
+		}
+	}
+
+	return counts, nil
 }
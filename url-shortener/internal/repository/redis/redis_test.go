@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository/repositorytest"
+	"urlshortener/internal/services/url"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDRESS")
+	if addr == "" {
+		t.Skip("set TEST_REDIS_ADDRESS to run against redis")
+	}
+
+	repositorytest.RunCacheRepository(t, func(t *testing.T) url.CacheRepository {
+		c, err := New(&config.Config{Cache: config.Cache{Addr: addr, Db: 15}})
+		require.NoError(t, err)
+		require.NoError(t, c.client.FlushDB(context.Background()).Err())
+		t.Cleanup(func() { c.Close() })
+
+		return c
+	})
+}
@@ -0,0 +1,270 @@
+// Package repositorytest holds the behavioural contract every storage and
+// cache backend must satisfy. Backends run it from their own tests with a
+// constructor returning a fresh, empty instance.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"urlshortener/internal/models"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/services/url"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunURLRepository checks the url repository returned by newRepo. It is
+// called once per subtest and must return an empty repository.
+func RunURLRepository(t *testing.T, newRepo func(t *testing.T) url.URLRepository) {
+	ctx := context.Background()
+
+	t.Run("save and get", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.SaveURL(ctx, "https://example.com/a", "alias"))
+
+		got, err := repo.GetURL(ctx, "alias")
+		require.NoError(t, err)
+		assert.NotZero(t, got.ID)
+		assert.Equal(t, "alias", got.ShortURL)
+		assert.Equal(t, "https://example.com/a", got.OriginalURL)
+		assert.WithinDuration(t, time.Now(), got.CreatedAt, time.Minute)
+	})
+
+	t.Run("duplicate alias", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.SaveURL(ctx, "https://example.com/a", "alias"))
+		err := repo.SaveURL(ctx, "https://example.com/b", "alias")
+		assert.ErrorIs(t, err, repository.ErrURLExists)
+
+		got, err := repo.GetURL(ctx, "alias")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", got.OriginalURL, "the first url is kept")
+	})
+
+	t.Run("concurrent saves of one alias", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const writers = 8
+		errs := make([]error, writers)
+
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = repo.SaveURL(ctx, fmt.Sprintf("https://example.com/%d", i), "alias")
+			}()
+		}
+		wg.Wait()
+
+		saved := 0
+		for _, err := range errs {
+			if err == nil {
+				saved++
+				continue
+			}
+			assert.ErrorIs(t, err, repository.ErrURLExists)
+		}
+		assert.Equal(t, 1, saved)
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.GetURL(ctx, "missing")
+		assert.ErrorIs(t, err, repository.ErrURLNotFound)
+		assert.Nil(t, got)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.SaveURL(ctx, "https://example.com/a", "alias"))
+		require.NoError(t, repo.DeleteURL(ctx, "alias"))
+
+		_, err := repo.GetURL(ctx, "alias")
+		assert.ErrorIs(t, err, repository.ErrURLNotFound)
+
+		// The alias is free again once deleted.
+		assert.NoError(t, repo.SaveURL(ctx, "https://example.com/b", "alias"))
+	})
+
+	t.Run("delete missing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.ErrorIs(t, repo.DeleteURL(ctx, "missing"), repository.ErrURLNotFound)
+	})
+
+	t.Run("tracking", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.SaveURL(ctx, "https://example.com/a", "alias"))
+		got, err := repo.GetURL(ctx, "alias")
+		require.NoError(t, err)
+		assert.False(t, got.TrackingDisabled, "links are tracked by default")
+
+		require.NoError(t, repo.SetTracking(ctx, "alias", false))
+		got, err = repo.GetURL(ctx, "alias")
+		require.NoError(t, err)
+		assert.True(t, got.TrackingDisabled)
+
+		require.NoError(t, repo.SetTracking(ctx, "alias", true))
+		got, err = repo.GetURL(ctx, "alias")
+		require.NoError(t, err)
+		assert.False(t, got.TrackingDisabled)
+
+		assert.ErrorIs(t, repo.SetTracking(ctx, "missing", false), repository.ErrURLNotFound)
+	})
+
+	t.Run("fetch all empty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// An empty repository is not an error.
+		urls, err := repo.FetchAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	t.Run("fetch all", func(t *testing.T) {
+		repo := newRepo(t)
+
+		aliases := []string{"first", "second", "third"}
+		for _, alias := range aliases {
+			require.NoError(t, repo.SaveURL(ctx, "https://example.com/"+alias, alias))
+		}
+		require.NoError(t, repo.DeleteURL(ctx, "second"))
+
+		urls, err := repo.FetchAll(ctx)
+		require.NoError(t, err)
+
+		// Urls come back in the order they were created.
+		got := make([]string, len(urls))
+		for i, u := range urls {
+			got[i] = u.ShortURL
+			assert.Equal(t, "https://example.com/"+u.ShortURL, u.OriginalURL)
+		}
+		assert.Equal(t, []string{"first", "third"}, got)
+	})
+}
+
+// RunCacheRepository checks the cache returned by newCache. It is called
+// once per subtest and must return an empty cache.
+func RunCacheRepository(t *testing.T, newCache func(t *testing.T) url.CacheRepository) {
+	ctx := context.Background()
+
+	value := &models.URL{
+		ID:          7,
+		OriginalURL: "https://example.com/a",
+		ShortURL:    "alias",
+		CreatedAt:   time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("set and get", func(t *testing.T) {
+		cache := newCache(t)
+
+		require.NoError(t, cache.Set(ctx, "url:alias", value, time.Minute))
+
+		var got models.URL
+		require.NoError(t, cache.Get(ctx, "url:alias", &got))
+		assert.Equal(t, value.ID, got.ID)
+		assert.Equal(t, value.OriginalURL, got.OriginalURL)
+		assert.Equal(t, value.ShortURL, got.ShortURL)
+		assert.True(t, value.CreatedAt.Equal(got.CreatedAt))
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		cache := newCache(t)
+
+		var got models.URL
+		assert.ErrorIs(t, cache.Get(ctx, "url:missing", &got), repository.ErrCacheMiss)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		cache := newCache(t)
+
+		require.NoError(t, cache.Set(ctx, "url:alias", value, time.Minute))
+		updated := *value
+		updated.OriginalURL = "https://example.com/b"
+		require.NoError(t, cache.Set(ctx, "url:alias", &updated, time.Minute))
+
+		var got models.URL
+		require.NoError(t, cache.Get(ctx, "url:alias", &got))
+		assert.Equal(t, "https://example.com/b", got.OriginalURL)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		cache := newCache(t)
+
+		require.NoError(t, cache.Set(ctx, "url:alias", value, time.Minute))
+		require.NoError(t, cache.Delete(ctx, "url:alias"))
+
+		var got models.URL
+		assert.ErrorIs(t, cache.Get(ctx, "url:alias", &got), repository.ErrCacheMiss)
+
+		// Deleting a missing key is not an error.
+		assert.NoError(t, cache.Delete(ctx, "url:alias"))
+	})
+
+	t.Run("delete many", func(t *testing.T) {
+		cache := newCache(t)
+
+		require.NoError(t, cache.Set(ctx, "url:alias", value, time.Minute))
+		_, err := cache.IncrBy(ctx, "clicks:alias", 3, 0)
+		require.NoError(t, err)
+		_, err = cache.IncrBy(ctx, "clicks:alias:day", 1, time.Minute)
+		require.NoError(t, err)
+		_, err = cache.IncrBy(ctx, "clicks:other", 1, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, cache.Delete(ctx, "url:alias", "clicks:alias", "clicks:alias:day", "clicks:missing"))
+		require.NoError(t, cache.Delete(ctx))
+
+		var got models.URL
+		assert.ErrorIs(t, cache.Get(ctx, "url:alias", &got), repository.ErrCacheMiss)
+		counts, err := cache.GetCounts(ctx, "clicks:alias", "clicks:alias:day", "clicks:other")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{0, 0, 1}, counts)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cache := newCache(t)
+
+		require.NoError(t, cache.Set(ctx, "url:alias", value, 100*time.Millisecond))
+
+		assert.Eventually(t, func() bool {
+			var got models.URL
+			return errors.Is(cache.Get(ctx, "url:alias", &got), repository.ErrCacheMiss)
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		cache := newCache(t)
+
+		n, err := cache.IncrBy(ctx, "clicks:alias", 1, 0)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+
+		n, err = cache.IncrBy(ctx, "clicks:alias", 41, 0)
+		require.NoError(t, err)
+		assert.EqualValues(t, 42, n)
+
+		_, err = cache.IncrBy(ctx, "clicks:alias:day", 1, 100*time.Millisecond)
+		require.NoError(t, err)
+
+		counts, err := cache.GetCounts(ctx, "clicks:alias", "clicks:missing", "clicks:alias:day")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{42, 0, 1}, counts)
+
+		assert.Eventually(t, func() bool {
+			counts, err := cache.GetCounts(ctx, "clicks:alias:day")
+			return err == nil && counts[0] == 0
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+}
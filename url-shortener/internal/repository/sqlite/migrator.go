@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"platform/schema"
+	"urlshortener/internal/config"
+	"urlshortener/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// NewMigrator returns a migrator for the embedded SQLite migrations.
+func NewMigrator(cfg *config.Config, l *slog.Logger) (*schema.Migrator, error) {
+	const op = "repository.sqlite.NewMigrator"
+
+	source, err := iofs.New(migrations.SQLiteFS, "sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The driver closes the database on Close, so it gets its own pool.
+	db, err := sql.Open("sqlite", DSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schema.New(m, l), nil
+}
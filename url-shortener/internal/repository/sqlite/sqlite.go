@@ -0,0 +1,133 @@
+// Package sqlite stores urls in a single SQLite file, for small deployments
+// that do not want to run Postgres.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"urlshortener/internal/config"
+	"urlshortener/internal/models"
+	"urlshortener/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Repository struct {
+	DB *sqlx.DB
+}
+
+func New(cfg *config.Config) (*Repository, error) {
+	const op = "repository.sqlite.New"
+
+	db, err := sqlx.Connect("sqlite", DSN(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// SQLite allows a single writer. One connection serialises writes in
+	// the pool instead of failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	return &Repository{db}, nil
+}
+
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", cfg.DB.Path)
+}
+
+func (r *Repository) Close() error {
+	return r.DB.Close()
+}
+
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.DB.PingContext(ctx)
+}
+
+func (r *Repository) GetURL(ctx context.Context, short_url string) (*models.URL, error) {
+	const op = "repository.sqlite.GetURL"
+
+	query := "SELECT id, original_url, short_url, created_at, tracking_disabled FROM url WHERE short_url=? LIMIT 1"
+
+	url := &models.URL{}
+	if err := r.DB.GetContext(ctx, url, query, short_url); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrURLNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+func (r *Repository) FetchAll(ctx context.Context) ([]*models.URL, error) {
+	const op = "repository.sqlite.FetchAll"
+
+	query := "SELECT id, short_url, original_url, created_at, tracking_disabled FROM url ORDER BY id"
+
+	var urls []*models.URL
+	if err := r.DB.SelectContext(ctx, &urls, query); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+func (r *Repository) SaveURL(ctx context.Context, urlToSave, short_url string) error {
+	const op = "repository.sqlite.SaveURL"
+
+	query := "INSERT INTO url (original_url, short_url) VALUES (?, ?)"
+
+	if _, err := r.DB.ExecContext(ctx, query, urlToSave, short_url); err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return fmt.Errorf("%s: %w", op, repository.ErrURLExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteURL(ctx context.Context, short_url string) error {
+	const op = "repository.sqlite.DeleteURL"
+
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM url WHERE short_url=?", short_url)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLNotFound)
+	}
+
+	return nil
+}
+
+func (r *Repository) SetTracking(ctx context.Context, short_url string, enabled bool) error {
+	const op = "repository.sqlite.SetTracking"
+
+	res, err := r.DB.ExecContext(ctx, "UPDATE url SET tracking_disabled=? WHERE short_url=?", !enabled, short_url)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrURLNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository/repositorytest"
+	"urlshortener/internal/repository/sqlite"
+	"urlshortener/internal/services/url"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	repositorytest.RunURLRepository(t, func(t *testing.T) url.URLRepository {
+		cfg := &config.Config{DB: config.DB{Path: filepath.Join(t.TempDir(), "tiny.db")}}
+
+		m, err := sqlite.NewMigrator(cfg, slogdiscard.NewDiscardLogger())
+		require.NoError(t, err)
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Close())
+
+		repo, err := sqlite.New(cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+
+		return repo
+	})
+}
@@ -0,0 +1,225 @@
+// Package clicks keeps the live click counters in line with the totals of
+// the analytics service.
+package clicks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+	"urlshortener/internal/config"
+	"urlshortener/internal/models"
+	urlservice "urlshortener/internal/services/url"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	analyticsTimeout = 5 * time.Second
+	// analyticsRequests bounds the concurrent requests to analytics.
+	analyticsRequests = 8
+	// creationSkew is how much earlier than the stored creation time the
+	// created event of the same link may be timed.
+	creationSkew = time.Minute
+)
+
+type URLLister interface {
+	GetAll(ctx context.Context) ([]*models.URL, error)
+}
+
+type Counters interface {
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+	GetCounts(ctx context.Context, keys ...string) ([]int64, error)
+}
+
+type Reconciler struct {
+	cfg      *config.Config
+	logger   *slog.Logger
+	urls     URLLister
+	counters Counters
+	client   *http.Client
+
+	// next is the position in the link list the next pass starts at.
+	next int
+}
+
+func NewReconciler(cfg *config.Config, l *slog.Logger, urls URLLister, counters Counters) *Reconciler {
+	return &Reconciler{
+		cfg:      cfg,
+		logger:   l,
+		urls:     urls,
+		counters: counters,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   analyticsTimeout,
+		},
+	}
+}
+
+// Reconcile raises the total click counters of the next ReconcileBatch links
+// that are below the analytics total to it, and returns the number of raised
+// counters. Counters ahead of analytics are left alone, since analytics lags
+// behind by the events still in flight. Links analytics could not be asked
+// about are logged and skipped until their next turn. Links whose alias was
+// used by a deleted link before are skipped too, since the analytics total
+// adds up the visits of both. Per-day counters are not reconciled.
+func (r *Reconciler) Reconcile(ctx context.Context) (int, error) {
+	const op = "services.clicks.Reconcile"
+
+	urls, err := r.urls.GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	batch := r.batch(urls)
+
+	keys := make([]string, len(batch))
+	for i, u := range batch {
+		keys[i] = urlservice.ClicksKey(u.ShortURL)
+	}
+	counts, err := r.counters.GetCounts(ctx, keys...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, analyticsRequests)
+		raised atomic.Int64
+	)
+	for i, u := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+
+			ok, err := r.reconcile(ctx, u, keys[i], counts[i])
+			switch {
+			case err != nil && ctx.Err() == nil:
+				r.logger.WarnContext(ctx, "failed to reconcile clicks", "short_url", u.ShortURL, "error", err)
+			case ok:
+				raised.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return int(raised.Load()), fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(raised.Load()), nil
+}
+
+// batch returns the next ReconcileBatch links, wrapping around at the end of
+// urls.
+func (r *Reconciler) batch(urls []*models.URL) []*models.URL {
+	size := r.cfg.Clicks.ReconcileBatch
+	if size <= 0 || size >= len(urls) {
+		r.next = 0
+		return urls
+	}
+
+	start := r.next % len(urls)
+	r.next = start + size
+	if r.next <= len(urls) {
+		return urls[start:r.next]
+	}
+
+	r.next -= len(urls)
+	return append(urls[start:len(urls):len(urls)], urls[:r.next]...)
+}
+
+// reconcile raises the counter at key, holding count, to the analytics total
+// of u and reports whether it was behind.
+func (r *Reconciler) reconcile(ctx context.Context, u *models.URL, key string, count int64) (bool, error) {
+	stats, err := r.analyticsStats(ctx, u.ShortURL)
+	if err != nil {
+		return false, err
+	}
+	// Analytics reports when the alias was first created. An earlier link
+	// under the same alias was deleted along with its clicks, which its
+	// total still counts.
+	if stats.CreatedAt != nil && stats.CreatedAt.Before(u.CreatedAt.Add(-creationSkew)) {
+		return false, nil
+	}
+	if stats.TotalVisits <= count {
+		return false, nil
+	}
+
+	// Adding the difference rather than setting the total keeps the clicks
+	// counted meanwhile.
+	if _, err := r.counters.IncrBy(ctx, key, stats.TotalVisits-count, 0); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Schedule calls Reconcile right away and then every interval until ctx is
+// done. Failures are logged and retried at the next tick.
+func (r *Reconciler) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		raised, err := r.Reconcile(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			r.logger.ErrorContext(ctx, "click reconciliation failed", "error", err)
+		case raised > 0:
+			r.logger.InfoContext(ctx, "click counters raised to analytics totals", "count", raised)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// linkStats holds the part of the analytics stats of a link the
+// reconciler reads.
+type linkStats struct {
+	CreatedAt   *time.Time `json:"created_at"`
+	TotalVisits int64      `json:"total_visits"`
+}
+
+// analyticsStats returns the stats analytics keeps for shortURL, empty when
+// it has none.
+func (r *Reconciler) analyticsStats(ctx context.Context, shortURL string) (linkStats, error) {
+	endpoint := r.cfg.Clicks.AnalyticsAddress + "/stats/" + url.PathEscape(shortURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return linkStats{}, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return linkStats{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return linkStats{}, nil
+	default:
+		return linkStats{}, fmt.Errorf("analytics responded %s", resp.Status)
+	}
+
+	var stats linkStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return linkStats{}, err
+	}
+
+	return stats, nil
+}
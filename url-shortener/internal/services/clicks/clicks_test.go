@@ -0,0 +1,143 @@
+package clicks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"urlshortener/internal/config"
+	"urlshortener/internal/models"
+	"urlshortener/internal/repository/memory"
+	urlservice "urlshortener/internal/services/url"
+	memorybroker "urlshortener/internal/transport/memory"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type urls []*models.URL
+
+func (u urls) GetAll(ctx context.Context) ([]*models.URL, error) { return u, nil }
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	analytics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stats/failing" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		totals := map[string]string{
+			"/stats/behind": `{"total_visits":5}`,
+			"/stats/ahead":  `{"total_visits":2}`,
+		}
+		body, ok := totals[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(analytics.Close)
+
+	ctx := context.Background()
+	cache := memory.NewCache()
+	_, err := cache.IncrBy(ctx, urlservice.ClicksKey("behind"), 3, 0)
+	require.NoError(t, err)
+	_, err = cache.IncrBy(ctx, urlservice.ClicksKey("ahead"), 4, 0)
+	require.NoError(t, err)
+
+	cfg := &config.Config{Clicks: config.Clicks{AnalyticsAddress: analytics.URL}}
+	// A link analytics fails on does not hold up the others.
+	links := urls{{ShortURL: "failing"}, {ShortURL: "behind"}, {ShortURL: "ahead"}, {ShortURL: "unseen"}}
+	r := NewReconciler(cfg, slogdiscard.NewDiscardLogger(), links, cache)
+
+	raised, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, raised)
+
+	counts, err := cache.GetCounts(ctx, urlservice.ClicksKey("behind"), urlservice.ClicksKey("ahead"), urlservice.ClicksKey("unseen"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5, 4, 0}, counts)
+}
+
+func TestReconcile_Batches(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		checked []string
+	)
+	analytics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		checked = append(checked, strings.TrimPrefix(r.URL.Path, "/stats/"))
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(analytics.Close)
+
+	cfg := &config.Config{Clicks: config.Clicks{AnalyticsAddress: analytics.URL, ReconcileBatch: 2}}
+	links := urls{{ShortURL: "a"}, {ShortURL: "b"}, {ShortURL: "c"}}
+	r := NewReconciler(cfg, slogdiscard.NewDiscardLogger(), links, memory.NewCache())
+
+	var passes [][]string
+	for range 3 {
+		checked = nil
+		_, err := r.Reconcile(context.Background())
+		require.NoError(t, err)
+		slices.Sort(checked)
+		passes = append(passes, checked)
+	}
+
+	assert.Equal(t, [][]string{{"a", "b"}, {"a", "c"}, {"b", "c"}}, passes)
+}
+
+func TestReconcile_ReusedAlias(t *testing.T) {
+	t.Parallel()
+
+	// Analytics still counts the visits of the link first created as
+	// "reused" an hour ago, and has just seen "fresh" created.
+	created := map[string]time.Time{
+		"/stats/reused": time.Now().Add(-time.Hour),
+		"/stats/fresh":  time.Now(),
+	}
+	analytics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		at, ok := created[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"created_at":"` + at.Format(time.RFC3339Nano) + `","total_visits":7}`))
+	}))
+	t.Cleanup(analytics.Close)
+
+	ctx := context.Background()
+	cfg := &config.Config{
+		Clicks: config.Clicks{AnalyticsAddress: analytics.URL, DayTTL: 48 * time.Hour},
+	}
+	cache := memory.NewCache()
+	repo := memory.New()
+	s := urlservice.New(cfg, slogdiscard.NewDiscardLogger(), repo, memorybroker.NewBroker(100), cache, nil, nil)
+	t.Cleanup(func() { s.Wait(context.Background()) })
+
+	require.NoError(t, s.SaveURL(ctx, "https://example.com/old", "reused"))
+	_, err := cache.IncrBy(ctx, urlservice.ClicksKey("reused"), 7, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(ctx, "reused"))
+	require.NoError(t, s.SaveURL(ctx, "https://example.com/new", "reused"))
+	require.NoError(t, s.SaveURL(ctx, "https://example.com/fresh", "fresh"))
+
+	r := NewReconciler(cfg, slogdiscard.NewDiscardLogger(), s, cache)
+	raised, err := r.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, raised)
+
+	counts, err := cache.GetCounts(ctx, urlservice.ClicksKey("reused"), urlservice.ClicksKey("fresh"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 7}, counts)
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+type component struct {
+	name  string
+	check CheckFunc
+}
+
+// Health runs readiness checks against the service dependencies. Once
+// Drain is called the service reports itself as not ready so load balancers
+// stop routing traffic to it before the server shuts down.
+type Health struct {
+	timeout    time.Duration
+	components []component
+	draining   atomic.Bool
+}
+
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout}
+}
+
+// Register adds a dependency check. It must be called before the server
+// starts serving requests.
+func (h *Health) Register(name string, check CheckFunc) {
+	h.components = append(h.components, component{name: name, check: check})
+}
+
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// Check runs all registered checks concurrently, each bounded by the
+// configured timeout.
+func (h *Health) Check(ctx context.Context) Report {
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]ComponentStatus, len(h.components)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range h.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status := h.run(ctx, c.check)
+
+			mu.Lock()
+			report.Components[c.name] = status
+			if status.Status != StatusUp {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if h.Draining() {
+		report.Status = StatusDraining
+	}
+
+	return report
+}
+
+func (h *Health) run(ctx context.Context, check CheckFunc) ComponentStatus {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	latency := float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		return ComponentStatus{Status: StatusDown, LatencyMs: latency, Error: err.Error()}
+	}
+	return ComponentStatus{Status: StatusUp, LatencyMs: latency}
+}
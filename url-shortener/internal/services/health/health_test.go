@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	h := New(50 * time.Millisecond)
+	h.Register("db", func(ctx context.Context) error { return nil })
+	h.Register("cache", func(ctx context.Context) error { return errors.New("connection refused") })
+	h.Register("broker", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := h.Check(context.Background())
+
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDown, report.Status)
+	require.Len(t, report.Components, 3)
+
+	assert.Equal(t, StatusUp, report.Components["db"].Status)
+	assert.Empty(t, report.Components["db"].Error)
+
+	assert.Equal(t, StatusDown, report.Components["cache"].Status)
+	assert.Equal(t, "connection refused", report.Components["cache"].Error)
+
+	assert.Equal(t, StatusDown, report.Components["broker"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["broker"].Error)
+	assert.GreaterOrEqual(t, report.Components["broker"].LatencyMs, float64(50))
+}
+
+func TestDrain(t *testing.T) {
+	t.Parallel()
+
+	h := New(time.Second)
+	h.Register("db", func(ctx context.Context) error { return nil })
+
+	require.True(t, h.Check(context.Background()).Ready())
+
+	h.Drain()
+
+	report := h.Check(context.Background())
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDraining, report.Status)
+	assert.Equal(t, StatusUp, report.Components["db"].Status)
+}
@@ -0,0 +1,145 @@
+package screener
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Blocklist is a set of domains loaded from a file, one per line. Lines
+// starting with # are comments. The file is reloaded whenever it changes.
+type Blocklist struct {
+	path    string
+	logger  *slog.Logger
+	watcher *fsnotify.Watcher
+
+	mu      sync.RWMutex
+	domains map[string]struct{}
+}
+
+func NewBlocklist(path string, l *slog.Logger) (*Blocklist, error) {
+	const op = "service.screener.NewBlocklist"
+
+	b := &Blocklist{
+		path:   filepath.Clean(path),
+		logger: l,
+	}
+
+	if err := b.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Watch the directory rather than the file so that editors and config
+	// management tools replacing the file via rename are picked up.
+	if err := watcher.Add(filepath.Dir(b.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	b.watcher = watcher
+
+	go b.watch()
+
+	return b, nil
+}
+
+func (b *Blocklist) Close() error {
+	return b.watcher.Close()
+}
+
+// Match reports whether host or one of its parent domains is blocked and
+// returns the matching entry.
+func (b *Blocklist) Match(host string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for domain := host; domain != ""; {
+		if _, ok := b.domains[domain]; ok {
+			return domain, true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	return "", false
+}
+
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.domains)
+}
+
+func (b *Blocklist) watch() {
+	for {
+		select {
+		case event, ok := <-b.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != b.path {
+				continue
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+
+			if err := b.load(); err != nil {
+				b.logger.Error("failed to reload blocklist", "path", b.path, "error", err)
+				continue
+			}
+			b.logger.Info("blocklist reloaded", "path", b.path, "domains", b.Len())
+		case err, ok := <-b.watcher.Errors:
+			if !ok {
+				return
+			}
+			b.logger.Error("blocklist watcher error", "path", b.path, "error", err)
+		}
+	}
+}
+
+func (b *Blocklist) load() error {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	domains := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		if domain := normalizeHost(line); domain != "" {
+			domains[domain] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"urlshortener/internal/config"
+)
+
+var ErrUnsafeURL = errors.New("unsafe url")
+
+// RejectError describes why a destination URL was refused. It unwraps to
+// ErrUnsafeURL so callers can match on either.
+type RejectError struct {
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnsafeURL, e.Reason)
+}
+
+func (e *RejectError) Unwrap() error {
+	return ErrUnsafeURL
+}
+
+func reject(format string, args ...any) error {
+	return &RejectError{Reason: fmt.Sprintf(format, args...)}
+}
+
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type Screener struct {
+	cfg            *config.Config
+	logger         *slog.Logger
+	allowedSchemes map[string]struct{}
+	shortDomains   []string
+	blocklist      *Blocklist
+	resolver       resolver
+}
+
+func New(cfg *config.Config, l *slog.Logger) (*Screener, error) {
+	const op = "service.screener.New"
+
+	schemes := make(map[string]struct{}, len(cfg.Screener.AllowedSchemes))
+	for _, scheme := range cfg.Screener.AllowedSchemes {
+		schemes[strings.ToLower(strings.TrimSpace(scheme))] = struct{}{}
+	}
+
+	domains := make([]string, 0, len(cfg.Screener.ShortDomains))
+	for _, domain := range cfg.Screener.ShortDomains {
+		if d := normalizeHost(domain); d != "" {
+			domains = append(domains, d)
+		}
+	}
+
+	s := &Screener{
+		cfg:            cfg,
+		logger:         l,
+		allowedSchemes: schemes,
+		shortDomains:   domains,
+		resolver:       net.DefaultResolver,
+	}
+
+	if cfg.Screener.BlocklistPath != "" {
+		bl, err := NewBlocklist(cfg.Screener.BlocklistPath, l)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		s.blocklist = bl
+	}
+
+	return s, nil
+}
+
+func (s *Screener) Close() error {
+	if s.blocklist == nil {
+		return nil
+	}
+	return s.blocklist.Close()
+}
+
+// Screen returns a *RejectError when rawURL must not be shortened.
+func (s *Screener) Screen(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return reject("url cannot be parsed")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if _, ok := s.allowedSchemes[scheme]; !ok {
+		return reject("scheme %q is not allowed", scheme)
+	}
+
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return reject("url has no host")
+	}
+
+	if ip, ok := parseIP(host); ok {
+		if isInternalIP(ip) {
+			return reject("host %s is a private or reserved address", host)
+		}
+		return nil
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return reject("host %s is a loopback name", host)
+	}
+
+	for _, domain := range s.shortDomains {
+		if matchDomain(host, domain) {
+			return reject("links to %s are not allowed", domain)
+		}
+	}
+
+	if s.blocklist != nil {
+		if domain, ok := s.blocklist.Match(host); ok {
+			return reject("domain %s is blocked", domain)
+		}
+	}
+
+	if s.cfg.Screener.ResolveHosts {
+		return s.screenResolved(ctx, host)
+	}
+
+	return nil
+}
+
+// screenResolved rejects hostnames that resolve to internal addresses. Lookup
+// failures are not treated as rejections, the link may simply be dead.
+func (s *Screener) screenResolved(ctx context.Context, host string) error {
+	if s.cfg.Screener.ResolveTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Screener.ResolveTimeout)
+		defer cancel()
+	}
+
+	addrs, err := s.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		s.logger.Warn("failed to resolve host", "host", host, "error", err)
+		return nil
+	}
+
+	for _, addr := range addrs {
+		ip, ok := netip.AddrFromSlice(addr.IP)
+		if ok && isInternalIP(ip.Unmap()) {
+			return reject("host %s resolves to a private or reserved address", host)
+		}
+	}
+
+	return nil
+}
+
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+func isInternalIP(ip netip.Addr) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		cgnat.Contains(ip)
+}
+
+// parseIP accepts canonical IPv4/IPv6 literals as well as the shorthand IPv4
+// forms browsers resolve (e.g. 2130706433, 0x7f.1, 0177.0.0.1).
+func parseIP(host string) (netip.Addr, bool) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.Unmap(), true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	nums := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		nums[i] = n
+	}
+
+	// The last part fills all remaining bytes, like inet_aton.
+	var v uint64
+	for i, n := range nums[:len(nums)-1] {
+		if n > 0xff {
+			return netip.Addr{}, false
+		}
+		v |= n << (8 * (3 - i))
+	}
+	last := nums[len(nums)-1]
+	if last >= 1<<(8*(5-len(nums))) {
+		return netip.Addr{}, false
+	}
+	v |= last
+
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}), true
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func matchDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
@@ -0,0 +1,122 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"urlshortener/internal/config"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubResolver map[string][]string
+
+func (r stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func newTestScreener(t *testing.T, blocklist string) *Screener {
+	t.Helper()
+
+	cfg := &config.Config{
+		Screener: config.Screener{
+			ShortDomains:   []string{"tiny.test"},
+			AllowedSchemes: []string{"http", "https"},
+			ResolveHosts:   true,
+		},
+	}
+
+	if blocklist != "" {
+		path := filepath.Join(t.TempDir(), "blocklist.txt")
+		require.NoError(t, os.WriteFile(path, []byte(blocklist), 0o644))
+		cfg.Screener.BlocklistPath = path
+	}
+
+	s, err := New(cfg, slogdiscard.NewDiscardLogger())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	s.resolver = stubResolver{
+		"example.com":      {"93.184.215.14"},
+		"internal.example": {"10.0.0.12"},
+	}
+
+	return s
+}
+
+func TestScreen(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScreener(t, "# known bad\nmalware.test\nphish.test # reported\n")
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "Public URL", url: "https://example.com/path?q=1"},
+		{name: "Unresolvable host", url: "https://unknown.example"},
+		{name: "Javascript scheme", url: "javascript:alert(1)", wantErr: true},
+		{name: "Data scheme", url: "data:text/html;base64,PHNjcmlwdD4=", wantErr: true},
+		{name: "File scheme", url: "file:///etc/passwd", wantErr: true},
+		{name: "Missing host", url: "http:///path", wantErr: true},
+		{name: "Loopback IPv4", url: "http://127.0.0.1:8080/", wantErr: true},
+		{name: "Loopback IPv6", url: "http://[::1]/", wantErr: true},
+		{name: "Mapped IPv6", url: "http://[::ffff:10.0.0.1]/", wantErr: true},
+		{name: "Private range", url: "http://192.168.1.1/", wantErr: true},
+		{name: "Link local metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "Decimal IPv4", url: "http://2130706433/", wantErr: true},
+		{name: "Hex IPv4", url: "http://0x7f.1/", wantErr: true},
+		{name: "Localhost name", url: "http://localhost:3000", wantErr: true},
+		{name: "Resolves to private", url: "http://internal.example/", wantErr: true},
+		{name: "Own short domain", url: "https://tiny.test/abc123", wantErr: true},
+		{name: "Own short subdomain", url: "https://www.Tiny.Test./abc123", wantErr: true},
+		{name: "Blocked domain", url: "https://malware.test", wantErr: true},
+		{name: "Blocked subdomain", url: "https://login.phish.test/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Screen(context.Background(), tt.url)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var rejectErr *RejectError
+			require.ErrorAs(t, err, &rejectErr)
+			assert.ErrorIs(t, err, ErrUnsafeURL)
+			assert.NotEmpty(t, rejectErr.Reason)
+		})
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScreener(t, "malware.test\n")
+	require.Error(t, s.Screen(context.Background(), "https://malware.test"))
+
+	err := os.WriteFile(s.cfg.Screener.BlocklistPath, []byte("spam.test\n"), 0o644)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return s.Screen(context.Background(), "https://malware.test") == nil &&
+			s.Screen(context.Background(), "https://spam.test") != nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -2,38 +2,39 @@ package url
 
 import (
 	"context"
+	"events"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 	"urlshortener/internal/config"
 	"urlshortener/internal/models"
 	"urlshortener/internal/services/userinfo"
 )
 
-const (
-	urlEventsTopic = "url_events"
-
-	eventCreated = "created"
-	eventVisited = "visited"
-	eventDeleted = "deleted"
-)
-
 type URLRepository interface {
 	SaveURL(ctx context.Context, urlToSave, short_url string) error
 	GetURL(ctx context.Context, short_url string) (*models.URL, error)
 	FetchAll(ctx context.Context) ([]*models.URL, error)
 	DeleteURL(ctx context.Context, short_url string) error
+	SetTracking(ctx context.Context, short_url string, enabled bool) error
 }
 
 type CacheRepository interface {
 	Get(ctx context.Context, key string, target any) error
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
-	Delete(ctx context.Context, key string) error
+	Delete(ctx context.Context, keys ...string) error
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+	GetCounts(ctx context.Context, keys ...string) ([]int64, error)
 }
 
 type MessageBroker interface {
-	Produce(msg any, topic, key string) error
+	Produce(ctx context.Context, event events.UrlEvent) error
+}
+
+type URLScreener interface {
+	Screen(ctx context.Context, rawURL string) error
 }
 
 type URLService struct {
@@ -43,6 +44,11 @@ type URLService struct {
 	kafka      MessageBroker
 	cache      CacheRepository
 	userInfo   *userinfo.Service
+	screener   URLScreener
+
+	// inflight tracks background event publishing so shutdown can wait
+	// for it before the producer is closed.
+	inflight sync.WaitGroup
 }
 
 func New(
@@ -52,6 +58,7 @@ func New(
 	k MessageBroker,
 	c CacheRepository,
 	us *userinfo.Service,
+	sc URLScreener,
 ) *URLService {
 	return &URLService{
 		cfg:        cfg,
@@ -60,30 +67,29 @@ func New(
 		kafka:      k,
 		cache:      c,
 		userInfo:   us,
+		screener:   sc,
 	}
 }
 
 func (s *URLService) SaveURL(ctx context.Context, original_url, short_url string) error {
 	const op = "service.url.SaveURL"
 
-	if err := s.repository.SaveURL(context.Background(), original_url, short_url); err != nil {
-		s.logger.Error("failed to save url in db:", "error", err)
-		return fmt.Errorf("%s: %w", op, err)
+	if s.screener != nil {
+		if err := s.screener.Screen(ctx, original_url); err != nil {
+			s.logger.WarnContext(ctx, "url rejected by screener", "url", original_url, "error", err)
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	urlEvent := models.UrlEvent{
-		EventType:  eventCreated,
-		ShortURL:   short_url,
-		OriginaUrl: original_url,
-		// TODO get from Auth service
-		UserID:    "",
-		EventTime: time.Now().UTC(),
+	if err := s.repository.SaveURL(ctx, original_url, short_url); err != nil {
+		s.logger.ErrorContext(ctx, "failed to save url in db:", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	go func() {
-		if err := s.kafka.Produce(urlEvent, urlEventsTopic, short_url); err != nil {
-			s.logger.Error("failed to produce message to broker", "msg", short_url, "error", err)
-		}
-	}()
+
+	urlEvent := events.New(events.KindCreated, short_url, time.Now())
+	urlEvent.OriginalURL = original_url
+	// TODO get UserID from Auth service
+	s.publish(ctx, urlEvent)
 
 	return nil
 }
@@ -94,60 +100,154 @@ func (s *URLService) GetURL(ctx context.Context, short_url string) (*models.URL,
 	// TODO get data from cache first
 	url, err := s.repository.GetURL(ctx, short_url)
 	if err != nil {
-		s.logger.Error("url cannot be found in db", "error", err)
+		s.logger.ErrorContext(ctx, "url cannot be found in db", "error", err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return url, nil
 }
 
+// Visit publishes a visited event for url and counts the click, unless
+// tracking is disabled for the link. Bots are published but not counted.
 func (s *URLService) Visit(ctx context.Context, url *models.URL, r *http.Request) error {
 	const op = "services.url.Visit"
 
-	rm, err := s.userInfo.ExtractRequestMeta(ctx, r)
+	if url.TrackingDisabled {
+		return nil
+	}
+
+	visit, err := s.userInfo.ExtractVisit(ctx, r)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	urlEvent := models.UrlEvent{
-		EventType:   "visited",
-		ShortURL:    url.ShortURL,
-		OriginaUrl:  url.OriginalURL,
-		UserID:      "",
-		EventTime:   time.Now().UTC(),
-		RequestMeta: rm,
+	urlEvent := events.New(events.KindVisited, url.ShortURL, time.Now())
+	urlEvent.OriginalURL = url.OriginalURL
+	urlEvent.Visit = visit
+	s.publish(ctx, urlEvent)
+
+	if !visit.IsBot {
+		s.countClick(ctx, url.ShortURL, urlEvent.EventTime)
 	}
-	go func() {
-		if err := s.kafka.Produce(urlEvent, urlEventsTopic, url.ShortURL); err != nil {
-			s.logger.Error("failed to produce message to broker", "msg", url.ShortURL, "error", err)
-		}
-	}()
 
 	return nil
 }
 
+// countClick increments the live click counters of short_url. They are a
+// convenience next to analytics, so a failure is only logged.
+func (s *URLService) countClick(ctx context.Context, short_url string, at time.Time) {
+	if _, err := s.cache.IncrBy(ctx, ClicksKey(short_url), 1, 0); err != nil {
+		s.logger.WarnContext(ctx, "failed to count click", "short_url", short_url, "error", err)
+		return
+	}
+	if _, err := s.cache.IncrBy(ctx, clicksDayKey(short_url, at), 1, s.cfg.Clicks.DayTTL); err != nil {
+		s.logger.WarnContext(ctx, "failed to count click", "short_url", short_url, "error", err)
+	}
+}
+
+// Clicks returns the live click counters of the given links.
+func (s *URLService) Clicks(ctx context.Context, short_urls ...string) (map[string]models.Clicks, error) {
+	const op = "services.url.Clicks"
+
+	now := time.Now()
+	keys := make([]string, 0, 2*len(short_urls))
+	for _, short_url := range short_urls {
+		keys = append(keys, ClicksKey(short_url), clicksDayKey(short_url, now))
+	}
+
+	counts, err := s.cache.GetCounts(ctx, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clicks := make(map[string]models.Clicks, len(short_urls))
+	for i, short_url := range short_urls {
+		clicks[short_url] = models.Clicks{Total: counts[2*i], Today: counts[2*i+1]}
+	}
+
+	return clicks, nil
+}
+
 func (s *URLService) DeleteURL(ctx context.Context, short_url string) error {
 	const op = "services.url.DeleteURL"
 
 	err := s.repository.DeleteURL(ctx, short_url)
 	if err != nil {
-		s.logger.Error("failed to delete url", "error", err)
+		s.logger.ErrorContext(ctx, "failed to delete url", "error", err)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	urlEvent := models.UrlEvent{
-		EventType: "deleted",
-		ShortURL:  short_url,
-		UserID:    "",
-		EventTime: time.Now().UTC(),
+	// The click counters go with the link, so a link created later under
+	// the same alias starts from zero. A per-day counter lives for DayTTL
+	// after the last click of its day.
+	now := time.Now()
+	keys := []string{ClicksKey(short_url)}
+	for day := range int(s.cfg.Clicks.DayTTL/(24*time.Hour)) + 2 {
+		keys = append(keys, clicksDayKey(short_url, now.AddDate(0, 0, -day)))
 	}
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete click counters", "error", err)
+	}
+
+	s.publish(ctx, events.New(events.KindDeleted, short_url, now))
+
+	return nil
+}
+
+// SetTracking enables or disables visit tracking for short_url.
+func (s *URLService) SetTracking(ctx context.Context, short_url string, enabled bool) error {
+	const op = "services.url.SetTracking"
+
+	if err := s.repository.SetTracking(ctx, short_url, enabled); err != nil {
+		s.logger.ErrorContext(ctx, "failed to set tracking", "short_url", short_url, "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// publish sends the event in the background so the request is not blocked on
+// the broker. The span context is kept but cancellation is dropped, since the
+// request context ends as soon as the handler returns.
+func (s *URLService) publish(ctx context.Context, event events.UrlEvent) {
+	ctx = context.WithoutCancel(ctx)
+
+	s.inflight.Add(1)
 	go func() {
-		if err := s.kafka.Produce(urlEvent, urlEventsTopic, short_url); err != nil {
-			s.logger.Error("failed to produce message to broker", "msg", short_url, "error", err)
+		defer s.inflight.Done()
+
+		if err := s.kafka.Produce(ctx, event); err != nil {
+			s.logger.ErrorContext(ctx, "failed to produce message to broker", "event_id", event.EventID, "short_url", event.ShortURL, "error", err)
 		}
 	}()
+}
 
-	return nil
+// Wait blocks until all background event publishing has finished or ctx is
+// done.
+func (s *URLService) Wait(ctx context.Context) error {
+	const op = "services.url.Wait"
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+// ClicksKey is the cache key of the total click counter of short_url.
+func ClicksKey(short_url string) string {
+	return "clicks:" + short_url
+}
+
+func clicksDayKey(short_url string, at time.Time) string {
+	return ClicksKey(short_url) + ":" + at.UTC().Format(time.DateOnly)
 }
 
 // TODO add Pagination Query
@@ -156,7 +256,7 @@ func (s *URLService) GetAll(ctx context.Context) ([]*models.URL, error) {
 
 	urls, err := s.repository.FetchAll(ctx)
 	if err != nil {
-		s.logger.Error("error getting url from db", "error", err)
+		s.logger.ErrorContext(ctx, "error getting url from db", "error", err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -0,0 +1,51 @@
+package url_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository"
+	"urlshortener/internal/repository/memory"
+	"urlshortener/internal/services/url"
+	memorybroker "urlshortener/internal/transport/memory"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T) (*url.URLService, *memory.Cache) {
+	cache := memory.NewCache()
+	cfg := &config.Config{Clicks: config.Clicks{DayTTL: 192 * time.Hour}}
+
+	s := url.New(cfg, slogdiscard.NewDiscardLogger(), memory.New(), memorybroker.NewBroker(100), cache, nil, nil)
+	t.Cleanup(func() { s.Wait(context.Background()) })
+
+	return s, cache
+}
+
+func TestDeleteURL_ClearsClicks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, cache := newService(t)
+	require.NoError(t, s.SaveURL(ctx, "https://example.com/a", "alias"))
+
+	// Clicks of today and of the oldest day whose counter is still kept.
+	counters := []string{url.ClicksKey("alias"), url.ClicksKey("alias") + ":" + time.Now().UTC().Format(time.DateOnly),
+		url.ClicksKey("alias") + ":" + time.Now().UTC().AddDate(0, 0, -9).Format(time.DateOnly)}
+	for _, key := range counters {
+		_, err := cache.IncrBy(ctx, key, 1, 0)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.DeleteURL(ctx, "alias"))
+
+	_, err := s.GetURL(ctx, "alias")
+	assert.ErrorIs(t, err, repository.ErrURLNotFound)
+
+	counts, err := cache.GetCounts(ctx, counters...)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 0, 0}, counts)
+}
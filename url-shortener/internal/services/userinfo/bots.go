@@ -0,0 +1,126 @@
+package userinfo
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"urlshortener/internal/config"
+)
+
+// botPatterns is the maintained list of user agent fragments that mark a
+// crawler or link-preview fetcher.
+//
+//go:embed bots.txt
+var botPatterns string
+
+// Bots classifies visits as automated. A visit is a bot when the user agent
+// library says so, its user agent contains one of the known fragments, or it
+// comes from one of the configured IP ranges.
+type Bots struct {
+	patterns []string
+	ranges   []netip.Prefix
+}
+
+// NewBots loads the built-in patterns, the extra patterns file and the IP
+// ranges file of cfg. Both files are optional.
+func NewBots(cfg config.Bots) (*Bots, error) {
+	const op = "service.userinfo.NewBots"
+
+	b := &Bots{}
+
+	patterns, err := readLines(strings.NewReader(botPatterns))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if cfg.PatternsPath != "" {
+		extra, err := readFile(cfg.PatternsPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		patterns = append(patterns, extra...)
+	}
+	for _, p := range patterns {
+		b.patterns = append(b.patterns, strings.ToLower(p))
+	}
+
+	if cfg.IPRangesPath != "" {
+		lines, err := readFile(cfg.IPRangesPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, line := range lines {
+			// Anything after the prefix, such as the ASN it was taken
+			// from, is a note for humans.
+			prefix, err := netip.ParsePrefix(strings.Fields(line)[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", op, cfg.IPRangesPath, err)
+			}
+			b.ranges = append(b.ranges, prefix.Masked())
+		}
+	}
+
+	return b, nil
+}
+
+// Match reports whether a visit with userAgent from ip is automated. uaBot is
+// the verdict of the user agent library.
+func (b *Bots) Match(uaBot bool, userAgent, ip string) bool {
+	if uaBot {
+		return true
+	}
+
+	ua := strings.ToLower(userAgent)
+	for _, p := range b.patterns {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+
+	if len(b.ranges) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range b.ranges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readLines(f)
+}
+
+// readLines returns the non-empty lines of r without # comments.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
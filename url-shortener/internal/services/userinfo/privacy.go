@@ -0,0 +1,53 @@
+package userinfo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// truncateIP zeroes the host part of ip: the last octet of an IPv4 address
+// and everything after the first 48 bits of an IPv6 address. Anything that is
+// not an IP address is dropped.
+func truncateIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+
+	bits := 48
+	if addr.Unmap().Is4() {
+		addr, bits = addr.Unmap(), 24
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
+
+// visitorID hashes the IP and user agent with a key derived from secret and
+// the UTC day of at. The same visitor gets the same ID for a day, which is
+// enough to count unique visitors, but cannot be followed across days, and
+// without the secret the IP cannot be recovered by hashing every address.
+func visitorID(secret string, at time.Time, ip, userAgent string) string {
+	day := hmac.New(sha256.New, []byte(secret))
+	day.Write([]byte(at.UTC().Format(time.DateOnly)))
+
+	mac := hmac.New(sha256.New, day.Sum(nil))
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// doNotTrack reports whether the client opted out of tracking with the DNT
+// or Global Privacy Control header.
+func doNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
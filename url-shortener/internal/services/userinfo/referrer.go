@@ -0,0 +1,124 @@
+package userinfo
+
+import (
+	"events"
+	"net"
+	"net/url"
+	"strings"
+	"urlshortener/internal/models"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Hosts of the well known traffic sources. An entry matches the host itself
+// and its subdomains; an entry ending in ".*" matches the name directly
+// under a public suffix, such as google.de or google.co.uk, with or without
+// www, but not other subdomains like docs.google.com. Webmail is checked
+// first, since mail.google.com is no search.
+var (
+	emailHosts = []string{
+		"mail.google.com", "outlook.live.com", "outlook.office.com", "outlook.office365.com",
+		"mail.yahoo.com", "mail.proton.me", "mail.aol.com", "mail.zoho.com", "app.fastmail.com",
+		"e.mail.ru", "mail.yandex.ru",
+	}
+	searchHosts = []string{
+		"google.*", "bing.com", "duckduckgo.com", "search.yahoo.com", "yandex.*", "baidu.com",
+		"ecosia.org", "search.brave.com", "startpage.com", "qwant.com", "ask.com", "naver.com",
+		"kagi.com", "perplexity.ai",
+	}
+	socialHosts = []string{
+		"facebook.com", "fb.com", "instagram.com", "t.co", "twitter.com", "x.com", "linkedin.com",
+		"lnkd.in", "reddit.com", "youtube.com", "pinterest.com", "tiktok.com", "threads.net",
+		"bsky.app", "mastodon.social", "news.ycombinator.com", "t.me", "whatsapp.com", "vk.com",
+		"quora.com", "tumblr.com", "discord.com",
+	}
+)
+
+// utmSources maps utm_medium values to the source they stand for, so
+// campaign links are classified even when the client sent no referrer, as
+// mail clients do.
+var utmSources = map[string]string{
+	"email":        events.SourceEmail,
+	"e-mail":       events.SourceEmail,
+	"newsletter":   events.SourceEmail,
+	"social":       events.SourceSocial,
+	"social-media": events.SourceSocial,
+	"social_media": events.SourceSocial,
+	"organic":      events.SourceSearch,
+	"cpc":          events.SourceSearch,
+	"ppc":          events.SourceSearch,
+	"paid-search":  events.SourceSearch,
+}
+
+// setTraffic fills the referrer and traffic source fields of v from the
+// referrer and the UTM parameters in the query of the short link request.
+// internalHosts are the hosts whose pages count as internal traffic.
+func setTraffic(v *models.RequestMeta, referrer string, query url.Values, internalHosts []string) {
+	v.UTMSource = query.Get("utm_source")
+	v.UTMMedium = query.Get("utm_medium")
+	v.UTMCampaign = query.Get("utm_campaign")
+
+	if u, err := url.Parse(referrer); err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https") {
+		v.ReferrerHost = normalizeHost(u.Host)
+		v.ReferrerPath = u.Path
+	}
+
+	v.Source = trafficSource(v.ReferrerHost, strings.ToLower(v.UTMMedium), internalHosts)
+}
+
+func trafficSource(host, medium string, internalHosts []string) string {
+	// The medium is set on purpose by whoever shared the link, so it wins
+	// over the referrer.
+	if source, ok := utmSources[medium]; ok {
+		return source
+	}
+
+	switch {
+	case host == "":
+		return events.SourceDirect
+	case matchHost(host, internalHosts):
+		return events.SourceInternal
+	case matchHost(host, emailHosts):
+		return events.SourceEmail
+	case matchHost(host, searchHosts):
+		return events.SourceSearch
+	case matchHost(host, socialHosts):
+		return events.SourceSocial
+	}
+	return events.SourceReferral
+}
+
+// normalizeHost lower-cases host and drops its port.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func matchHost(host string, entries []string) bool {
+	for _, entry := range entries {
+		entry = normalizeHost(entry)
+		if name, ok := strings.CutSuffix(entry, ".*"); ok {
+			if matchAnySuffix(host, name) {
+				return true
+			}
+			continue
+		}
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnySuffix reports whether host is name or www.name under a public
+// suffix of the ICANN section of the list.
+func matchAnySuffix(host, name string) bool {
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if !icann {
+		return false
+	}
+	host = strings.TrimPrefix(host, "www.")
+	return host == name+"."+suffix
+}
@@ -7,14 +7,43 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"time"
+	"urlshortener/internal/config"
+	"urlshortener/internal/metrics"
 	"urlshortener/internal/models"
 
 	"github.com/mssola/useragent"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-const ipAPIAddress = "http://ip-api.com/json"
+const geoLookupTimeout = 5 * time.Second
 
-type Service struct{}
+type Service struct {
+	geoAddress string
+	privacy    config.Privacy
+	bots       *Bots
+	traffic    config.Traffic
+	client     *http.Client
+	now        func() time.Time
+}
+
+// New returns a service resolving locations through the ip-api compatible
+// endpoint at geoAddress, flagging automated visits with bots, classifying
+// traffic sources with traffic and applying privacy to the visits it
+// extracts.
+func New(geoAddress string, privacy config.Privacy, bots *Bots, traffic config.Traffic) *Service {
+	return &Service{
+		geoAddress: geoAddress,
+		privacy:    privacy,
+		bots:       bots,
+		traffic:    traffic,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   geoLookupTimeout,
+		},
+		now: time.Now,
+	}
+}
 
 type IPApiResponse struct {
 	Status  string `json:"status"`
@@ -25,12 +54,30 @@ type IPApiResponse struct {
 
 type UserAgent struct {
 	OS, Device, Browser string
+	Bot                 bool
+}
+
+func (s *Service) GetGeoInfo(ctx context.Context, ip string) (*IPApiResponse, error) {
+	start := time.Now()
+	data, err := s.getGeoInfo(ctx, ip)
+	metrics.GeoLookupDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.GeoLookupErrors.Inc()
+		return nil, err
+	}
+
+	return data, nil
 }
 
-func (s *Service) GetGeoInfo(ip string) (*IPApiResponse, error) {
+func (s *Service) getGeoInfo(ctx context.Context, ip string) (*IPApiResponse, error) {
 	const op = "service.userinfo.GetGeoInfo"
 
-	resp, err := http.Get(fmt.Sprintf("%s/%s", ipAPIAddress, ip))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", s.geoAddress, ip), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -48,6 +95,13 @@ func (s *Service) ParseUserAgent(user_agent string) UserAgent {
 	ua := useragent.New(user_agent)
 	browser, _ := ua.Browser()
 	os := ua.OS()
+	// Crawlers like "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+	// come out with a piece of their info url as the browser; their product
+	// name says more.
+	if strings.ContainsAny(browser, ":/") {
+		browser, _, _ = strings.Cut(user_agent, " ")
+		browser, _, _ = strings.Cut(browser, "/")
+	}
 
 	device := "Desktop"
 	if ua.Mobile() {
@@ -60,23 +114,40 @@ func (s *Service) ParseUserAgent(user_agent string) UserAgent {
 		OS:      os,
 		Device:  device,
 		Browser: browser,
+		Bot:     ua.Bot(),
 	}
 }
 
-func (s *Service) ExtractRequestMeta(ctx context.Context, r *http.Request) (models.RequestMeta, error) {
+// ExtractVisit describes the client behind r for a visited event, revealing
+// only as much as the privacy settings allow. A client that opted out of
+// tracking gets an empty visit, which is still flagged when it is a bot.
+func (s *Service) ExtractVisit(ctx context.Context, r *http.Request) (models.RequestMeta, error) {
 	ip := getIP(r)
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
 
-	geoInfo, err := s.GetGeoInfo(ip)
+	ua := s.ParseUserAgent(userAgent)
+	isBot := s.bots.Match(ua.Bot, userAgent, ip)
+	if isBot {
+		ua.Device = "Bot"
+	}
+
+	if s.privacy.HonorDNT && doNotTrack(r) {
+		return models.RequestMeta{IsBot: isBot}, nil
+	}
+
+	publicIP := ip
+	if s.privacy.IPMode != config.IPModeFull {
+		publicIP = truncateIP(ip)
+	}
+
+	geoInfo, err := s.GetGeoInfo(ctx, publicIP)
 	if err != nil {
 		return models.RequestMeta{}, err
 	}
 
-	ua := s.ParseUserAgent(userAgent)
-
-	return models.RequestMeta{
-		IPAddress:  ip,
+	visit := models.RequestMeta{
+		IPAddress:  publicIP,
 		UserAgent:  userAgent,
 		Referrer:   referrer,
 		Country:    geoInfo.Country,
@@ -85,7 +156,18 @@ func (s *Service) ExtractRequestMeta(ctx context.Context, r *http.Request) (mode
 		Browser:    ua.Browser,
 		OS:         ua.OS,
 		DeviceType: ua.Device,
-	}, nil
+		IsBot:      isBot,
+	}
+	if s.privacy.IPMode == config.IPModeHash {
+		visit.IPAddress = ""
+	}
+	if s.privacy.HashSecret != "" {
+		visit.VisitorID = visitorID(s.privacy.HashSecret, s.now(), ip, userAgent)
+	}
+	// Links on the shortener's own pages are internal too.
+	setTraffic(&visit, referrer, r.URL.Query(), append([]string{r.Host}, s.traffic.InternalHosts...))
+
+	return visit, nil
 }
 
 func getIP(r *http.Request) string {
@@ -0,0 +1,293 @@
+package userinfo
+
+import (
+	"context"
+	"encoding/json"
+	"events"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"urlshortener/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.77", "203.0.113.0"},
+		{"::ffff:203.0.113.77", "203.0.113.0"},
+		{"2001:db8:abcd:1234:5678::1", "2001:db8:abcd::"},
+		{"not-an-ip", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, truncateIP(tt.ip), tt.ip)
+	}
+}
+
+func TestVisitorID(t *testing.T) {
+	day := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	id := visitorID("secret", day, "203.0.113.77", "Mozilla/5.0")
+
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, visitorID("secret", day.Add(14*time.Hour), "203.0.113.77", "Mozilla/5.0"), "stable within a day")
+	assert.NotEqual(t, id, visitorID("secret", day.AddDate(0, 0, 1), "203.0.113.77", "Mozilla/5.0"), "rotates daily")
+	assert.NotEqual(t, id, visitorID("other", day, "203.0.113.77", "Mozilla/5.0"))
+	assert.NotEqual(t, id, visitorID("secret", day, "203.0.113.78", "Mozilla/5.0"))
+}
+
+func TestBots(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns.txt")
+	ranges := filepath.Join(dir, "ranges.txt")
+	require.NoError(t, os.WriteFile(patterns, []byte("# in-house monitor\nAcmeProbe\n"), 0o644))
+	require.NoError(t, os.WriteFile(ranges, []byte("66.249.64.0/19 AS15169\n2001:4860:4801::/48\n"), 0o644))
+
+	bots, err := NewBots(config.Bots{PatternsPath: patterns, IPRangesPath: ranges})
+	require.NoError(t, err)
+
+	const browser = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	tests := []struct {
+		name  string
+		uaBot bool
+		ua    string
+		ip    string
+		want  bool
+	}{
+		{name: "browser", ua: browser, ip: "203.0.113.10"},
+		{name: "user agent library", uaBot: true, ua: "Googlebot/2.1", ip: "203.0.113.10", want: true},
+		{name: "preview fetcher", ua: "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", ip: "203.0.113.10", want: true},
+		{name: "facebook preview", ua: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", ip: "203.0.113.10", want: true},
+		{name: "extra pattern", ua: "acmeprobe/3", ip: "203.0.113.10", want: true},
+		{name: "ipv4 range", ua: browser, ip: "66.249.66.1", want: true},
+		{name: "ipv6 range", ua: browser, ip: "2001:4860:4801:10::1", want: true},
+		{name: "mapped ipv4", ua: browser, ip: "::ffff:66.249.66.1", want: true},
+		{name: "invalid ip", ua: browser, ip: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, bots.Match(tt.uaBot, tt.ua, tt.ip))
+		})
+	}
+
+	_, err = NewBots(config.Bots{IPRangesPath: patterns})
+	assert.Error(t, err, "patterns are not ranges")
+}
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want UserAgent
+	}{
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: UserAgent{Browser: "Chrome", OS: "Windows 10", Device: "Desktop"},
+		},
+		{
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: UserAgent{Browser: "Googlebot", Device: "Bot", Bot: true},
+		},
+		{
+			ua:   "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			want: UserAgent{Browser: "Slackbot-LinkExpanding", Device: "Bot", Bot: true},
+		},
+	}
+
+	s := &Service{}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, s.ParseUserAgent(tt.ua), tt.ua)
+	}
+}
+
+func TestExtractVisit(t *testing.T) {
+	var lookups []string
+	geo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups = append(lookups, r.URL.Path)
+		json.NewEncoder(w).Encode(IPApiResponse{Status: "success", Country: "Germany"})
+	}))
+	defer geo.Close()
+
+	bots, err := NewBots(config.Bots{})
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	const ua = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	tests := []struct {
+		name       string
+		privacy    config.Privacy
+		headers    map[string]string
+		want       events.Visit
+		wantLookup string
+	}{
+		{
+			name:       "full",
+			privacy:    config.Privacy{IPMode: config.IPModeFull},
+			want:       events.Visit{IPAddress: "203.0.113.77", Country: "Germany", Source: events.SourceDirect},
+			wantLookup: "/203.0.113.77",
+		},
+		{
+			name:       "truncate",
+			privacy:    config.Privacy{IPMode: config.IPModeTruncate},
+			want:       events.Visit{IPAddress: "203.0.113.0", Country: "Germany", Source: events.SourceDirect},
+			wantLookup: "/203.0.113.0",
+		},
+		{
+			name:    "hash",
+			privacy: config.Privacy{IPMode: config.IPModeHash, HashSecret: "secret"},
+			want: events.Visit{
+				Country:   "Germany",
+				VisitorID: visitorID("secret", now, "203.0.113.77", ua),
+				Source:    events.SourceDirect,
+			},
+			wantLookup: "/203.0.113.0",
+		},
+		{
+			name:    "do not track",
+			privacy: config.Privacy{IPMode: config.IPModeFull, HonorDNT: true},
+			headers: map[string]string{"DNT": "1"},
+		},
+		{
+			name:    "global privacy control",
+			privacy: config.Privacy{IPMode: config.IPModeFull, HonorDNT: true},
+			headers: map[string]string{"Sec-GPC": "1"},
+		},
+		{
+			name:    "bot with do not track",
+			privacy: config.Privacy{IPMode: config.IPModeFull, HonorDNT: true},
+			headers: map[string]string{"DNT": "1", "User-Agent": "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"},
+			want:    events.Visit{IsBot: true},
+		},
+		{
+			name:       "do not track ignored",
+			privacy:    config.Privacy{IPMode: config.IPModeFull},
+			headers:    map[string]string{"DNT": "1"},
+			want:       events.Visit{IPAddress: "203.0.113.77", Country: "Germany", Source: events.SourceDirect},
+			wantLookup: "/203.0.113.77",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups = nil
+			s := New(geo.URL, tt.privacy, bots, config.Traffic{})
+			s.now = func() time.Time { return now }
+
+			r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			r.Header.Set("User-Agent", ua)
+			r.Header.Set("X-Forwarded-For", "203.0.113.77")
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			got, err := s.ExtractVisit(context.Background(), r)
+			require.NoError(t, err)
+
+			if tt.wantLookup == "" {
+				assert.Equal(t, tt.want, got)
+				assert.Empty(t, lookups, "no enrichment")
+				return
+			}
+
+			// The user agent details are not what this test is about.
+			assert.Equal(t, ua, got.UserAgent)
+			got.UserAgent, got.Browser, got.OS, got.DeviceType = "", "", "", ""
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, []string{tt.wantLookup}, lookups)
+		})
+	}
+}
+
+func TestSetTraffic(t *testing.T) {
+	internal := []string{"tiny.example", "example.com"}
+
+	tests := []struct {
+		name     string
+		referrer string
+		query    string
+		want     events.Visit
+	}{
+		{
+			name: "direct",
+			want: events.Visit{Source: events.SourceDirect},
+		},
+		{
+			name:     "search with the query left out",
+			referrer: "https://www.google.co.uk/search?q=tiny",
+			want:     events.Visit{ReferrerHost: "www.google.co.uk", ReferrerPath: "/search", Source: events.SourceSearch},
+		},
+		{
+			name:     "search without www",
+			referrer: "https://yandex.ru/search/",
+			want:     events.Visit{ReferrerHost: "yandex.ru", ReferrerPath: "/search/", Source: events.SourceSearch},
+		},
+		{
+			name:     "search name under another domain",
+			referrer: "https://google.evil.com/",
+			want:     events.Visit{ReferrerHost: "google.evil.com", ReferrerPath: "/", Source: events.SourceReferral},
+		},
+		{
+			name:     "other service of a search engine",
+			referrer: "https://docs.google.com/document/d/1",
+			want:     events.Visit{ReferrerHost: "docs.google.com", ReferrerPath: "/document/d/1", Source: events.SourceReferral},
+		},
+		{
+			name:     "webmail before search",
+			referrer: "https://mail.google.com/mail/u/0/",
+			want:     events.Visit{ReferrerHost: "mail.google.com", ReferrerPath: "/mail/u/0/", Source: events.SourceEmail},
+		},
+		{
+			name:     "social subdomain",
+			referrer: "https://M.Facebook.com/",
+			want:     events.Visit{ReferrerHost: "m.facebook.com", ReferrerPath: "/", Source: events.SourceSocial},
+		},
+		{
+			name:     "internal with port",
+			referrer: "http://blog.example.com:8080/post",
+			want:     events.Visit{ReferrerHost: "blog.example.com", ReferrerPath: "/post", Source: events.SourceInternal},
+		},
+		{
+			name:     "referral",
+			referrer: "https://googleblog.example.org/",
+			want:     events.Visit{ReferrerHost: "googleblog.example.org", ReferrerPath: "/", Source: events.SourceReferral},
+		},
+		{
+			name:     "not a web page",
+			referrer: "android-app://com.slack/",
+			want:     events.Visit{Source: events.SourceDirect},
+		},
+		{
+			name:  "campaign without referrer",
+			query: "utm_source=newsletter&utm_medium=Email&utm_campaign=spring",
+			want:  events.Visit{Source: events.SourceEmail, UTMSource: "newsletter", UTMMedium: "Email", UTMCampaign: "spring"},
+		},
+		{
+			name:     "unknown medium keeps the referrer source",
+			referrer: "https://t.co/abc",
+			query:    "utm_medium=banner&utm_campaign=launch",
+			want:     events.Visit{ReferrerHost: "t.co", ReferrerPath: "/abc", Source: events.SourceSocial, UTMMedium: "banner", UTMCampaign: "launch"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			var got events.Visit
+			setTraffic(&got, tt.referrer, query, internal)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package httpserver
+
+import (
+	"net/http"
+	"urlshortener/internal/services/health"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Liveness godoc
+// @Summary      Liveness probe
+// @Description  reports whether the process is running, dependencies are not checked
+// @Tags         Health
+// @Success      200
+// @Router       /healthz [get]
+func (s server) HandleLiveness(c echo.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+// Readiness godoc
+// @Summary      Readiness probe
+// @Description  checks Postgres, Redis and Kafka and reports the status and latency of each
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /readyz [get]
+func (s server) HandleReadiness(c echo.Context) error {
+	if s.health == nil {
+		return c.JSON(http.StatusOK, health.Report{Status: health.StatusUp})
+	}
+
+	report := s.health.Check(c.Request().Context())
+	if !report.Ready() {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"urlshortener/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		err := next(c)
+
+		status := c.Response().Status
+		if err != nil {
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				status = he.Code
+			} else {
+				status = http.StatusInternalServerError
+			}
+		}
+
+		// c.Path() is the registered route template, which keeps the
+		// label cardinality bounded by the number of routes.
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
@@ -16,6 +16,43 @@ type URLService struct {
 	mock.Mock
 }
 
+// Clicks provides a mock function with given fields: ctx, short_urls
+func (_m *URLService) Clicks(ctx context.Context, short_urls ...string) (map[string]models.Clicks, error) {
+	_va := make([]interface{}, len(short_urls))
+	for _i := range short_urls {
+		_va[_i] = short_urls[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Clicks")
+	}
+
+	var r0 map[string]models.Clicks
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) (map[string]models.Clicks, error)); ok {
+		return rf(ctx, short_urls...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, ...string) map[string]models.Clicks); ok {
+		r0 = rf(ctx, short_urls...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]models.Clicks)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, ...string) error); ok {
+		r1 = rf(ctx, short_urls...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteURL provides a mock function with given fields: ctx, short_url
 func (_m *URLService) DeleteURL(ctx context.Context, short_url string) error {
 	ret := _m.Called(ctx, short_url)
@@ -112,6 +149,24 @@ func (_m *URLService) SaveURL(ctx context.Context, original_url string, short_ur
 	return r0
 }
 
+// SetTracking provides a mock function with given fields: ctx, short_url, enabled
+func (_m *URLService) SetTracking(ctx context.Context, short_url string, enabled bool) error {
+	ret := _m.Called(ctx, short_url, enabled)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTracking")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) error); ok {
+		r0 = rf(ctx, short_url, enabled)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Visit provides a mock function with given fields: ctx, url, r
 func (_m *URLService) Visit(ctx context.Context, url *models.URL, r *http.Request) error {
 	ret := _m.Called(ctx, url, r)
@@ -1,15 +1,23 @@
 package httpserver
 
 import (
-	"context"
 	"log/slog"
 	"net/http"
+	"urlshortener/internal/metrics"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
+// ReservedAliases are the aliases of the static routes registered before
+// /:short_url, and top, which the /stats/top leaderboard of the analytics
+// service shadows in /stats/:short_url. Routes match case-sensitively and
+// static ones win, so a link under one of these could not be redirected or
+// would have no stats page; Top and the like are fine.
+var ReservedAliases = []string{"up", "metrics", "healthz", "readyz", "top"}
+
 // @title           		   Tiny URL API
 // @version        			   1.0
 // @description     		   This is a sample server celler server.
@@ -18,6 +26,13 @@ import (
 // @securityDefinitions.basic  BasicAuth
 func (s server) registerRoutes(e *echo.Echo) {
 	e.Use(middleware.RequestID())
+	e.Use(otelecho.Middleware(s.cfg.AppName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/up", "/healthz", "/readyz":
+			return true
+		}
+		return false
+	})))
 	e.Use(middleware.Recover())
 
 	e.IPExtractor = echo.ExtractIPFromXFFHeader()
@@ -29,12 +44,12 @@ func (s server) registerRoutes(e *echo.Echo) {
 		HandleError: true,
 		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
 			if v.Error == nil {
-				s.logger.LogAttrs(context.Background(), slog.LevelInfo, "REQUEST",
+				s.logger.LogAttrs(c.Request().Context(), slog.LevelInfo, "REQUEST",
 					slog.String("uri", v.URI),
 					slog.Int("status", v.Status),
 				)
 			} else {
-				s.logger.LogAttrs(context.Background(), slog.LevelError, "REQUEST_ERROR",
+				s.logger.LogAttrs(c.Request().Context(), slog.LevelError, "REQUEST_ERROR",
 					slog.String("uri", v.URI),
 					slog.Int("status", v.Status),
 					slog.String("err", v.Error.Error()),
@@ -44,18 +59,24 @@ func (s server) registerRoutes(e *echo.Echo) {
 		},
 	}))
 
+	e.Use(metricsMiddleware)
+
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"http://localhost:8080"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
 	}))
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
 	e.GET("/up", func(c echo.Context) error {
 		return c.NoContent(http.StatusOK)
 	})
+	e.GET("/healthz", s.HandleLiveness)
+	e.GET("/readyz", s.HandleReadiness)
 	e.POST("/url", s.HandleURLSave)
 	e.GET("/:short_url", s.HandleURLRedirect)
 	e.GET("/url/:short_url", s.HandleURLGet)
 	e.GET("/url/all", s.HandleURLGetAll)
 	e.DELETE("/url/:short_url", s.HandleURLDelete)
+	e.PUT("/url/:short_url/tracking", s.HandleURLTracking)
 }
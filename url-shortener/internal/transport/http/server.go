@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 	"urlshortener/internal/config"
+	"urlshortener/internal/services/health"
 	"urlshortener/internal/services/userinfo"
 
 	"github.com/labstack/echo/v4"
@@ -15,10 +16,11 @@ type server struct {
 	logger     *slog.Logger
 	urlService URLService
 	userInfo   *userinfo.Service
+	health     *health.Health
 	srv        *http.Server
 }
 
-func New(cfg *config.Config, l *slog.Logger, us URLService) server {
+func New(cfg *config.Config, l *slog.Logger, us URLService, h *health.Health) server {
 	e := echo.New()
 	s := &http.Server{
 		Addr:         cfg.HttpServer.Address,
@@ -32,6 +34,7 @@ func New(cfg *config.Config, l *slog.Logger, us URLService) server {
 		cfg:        cfg,
 		logger:     l,
 		urlService: us,
+		health:     h,
 		srv:        s,
 	}
 
@@ -40,6 +43,11 @@ func New(cfg *config.Config, l *slog.Logger, us URLService) server {
 	return server
 }
 
+// Handler exposes the routes without a listener, for use with httptest.
+func (s server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
 func (s server) Run() error {
 	return s.srv.ListenAndServe()
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 	"urlshortener/internal/models"
 	"urlshortener/internal/repository"
+	"urlshortener/internal/services/screener"
 	random "urlshortener/internal/utils"
 
 	"github.com/labstack/echo/v4"
@@ -17,6 +19,10 @@ type Request struct {
 	ShortURL string `json:"short_url,omitempty"`
 }
 
+type TrackingRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
 type Response struct {
 	Message any `json:"message"`
 }
@@ -36,6 +42,8 @@ type URLService interface {
 	GetAll(ctx context.Context) ([]*models.URL, error)
 	Visit(ctx context.Context, url *models.URL, r *http.Request) error
 	DeleteURL(ctx context.Context, short_url string) error
+	SetTracking(ctx context.Context, short_url string, enabled bool) error
+	Clicks(ctx context.Context, short_urls ...string) (map[string]models.Clicks, error)
 }
 
 // SaveURL godoc
@@ -47,6 +55,7 @@ type URLService interface {
 // @Success      200  {object}  Response
 // @Failure		 400  {object}  Response
 // @Failure		 404  {object}  Response
+// @Failure		 422  {object}  Response
 // @Failure		 500  {object}  Response
 // @Router       /url [post]
 func (s server) HandleURLSave(c echo.Context) error {
@@ -62,6 +71,9 @@ func (s server) HandleURLSave(c echo.Context) error {
 	if errs := validateWithTrans(req); errs != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, Response{errs})
 	}
+	if slices.Contains(ReservedAliases, req.ShortURL) {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Alias " + req.ShortURL + " is reserved"})
+	}
 
 	short_url := req.ShortURL
 	if short_url == "" {
@@ -74,6 +86,11 @@ func (s server) HandleURLSave(c echo.Context) error {
 			return echo.NewHTTPError(http.StatusBadRequest, Response{"This URL already exists"})
 		}
 
+		var rejectErr *screener.RejectError
+		if errors.As(err, &rejectErr) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, Response{"URL rejected: " + rejectErr.Reason})
+		}
+
 		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to add URL"})
 	}
 
@@ -131,6 +148,7 @@ func (s server) HandleURLGetAll(c echo.Context) error {
 
 		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get URL"})
 	}
+	s.attachClicks(ctx, urls...)
 
 	return c.JSON(http.StatusOK, urls)
 }
@@ -160,10 +178,36 @@ func (s server) HandleURLGet(c echo.Context) error {
 
 		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to get URL"})
 	}
+	s.attachClicks(ctx, url)
 
 	return c.JSON(http.StatusOK, url)
 }
 
+// attachClicks fills in the live click counters of urls. Without them the
+// links are still served.
+func (s server) attachClicks(ctx context.Context, urls ...*models.URL) {
+	if len(urls) == 0 {
+		return
+	}
+
+	short_urls := make([]string, len(urls))
+	for i, url := range urls {
+		short_urls[i] = url.ShortURL
+	}
+
+	clicks, err := s.urlService.Clicks(ctx, short_urls...)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get click counters", "error", err)
+		return
+	}
+
+	for _, url := range urls {
+		if c, ok := clicks[url.ShortURL]; ok {
+			url.Clicks = &c
+		}
+	}
+}
+
 // DeleteUrl godoc
 // @Summary      Delete URL
 // @Description  delete url by short url
@@ -192,3 +236,41 @@ func (s server) HandleURLDelete(c echo.Context) error {
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// SetTracking godoc
+// @Summary      Set URL tracking
+// @Description  enable or disable visit tracking for a short url
+// @Tags         URL
+// @Accept       json
+// @Produce      json
+// @Param        short_url path string true "Short of the URL"
+// @Param        body body TrackingRequest true "Tracking"
+// @Success      204
+// @Failure		 400  {object}  Response
+// @Failure		 404  {object}  Response
+// @Router       /url/{short_url}/tracking [put]
+func (s server) HandleURLTracking(c echo.Context) error {
+	short_url := c.Param("short_url")
+	if strings.TrimSpace(short_url) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{"Short URL cannot be empty"})
+	}
+
+	var req TrackingRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.ErrBadRequest
+	}
+	if errs := validateWithTrans(req); errs != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, Response{errs})
+	}
+
+	ctx := c.Request().Context()
+	if err := s.urlService.SetTracking(ctx, short_url, *req.Enabled); err != nil {
+		if errors.Is(err, repository.ErrURLNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, Response{"URL not found"})
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, Response{"Failed to update tracking"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
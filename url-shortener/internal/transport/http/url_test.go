@@ -5,16 +5,22 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"events"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 	"urlshortener/internal/config"
 	"urlshortener/internal/models"
 	"urlshortener/internal/repository"
+	"urlshortener/internal/repository/memory"
+	"urlshortener/internal/services/screener"
+	"urlshortener/internal/services/url"
 	httpserver "urlshortener/internal/transport/http"
 	"urlshortener/internal/transport/http/mocks"
+	memorybroker "urlshortener/internal/transport/memory"
 	slogdiscard "urlshortener/internal/utils/logger/handlers"
 
 	"github.com/labstack/echo/v4"
@@ -63,6 +69,42 @@ func TestSaveURL(t *testing.T) {
 			expectedErrMsg: "Invalid URL",
 			wantErr:        true,
 		},
+		{
+			name:           "Reserved alias metrics",
+			url:            "http://example.com",
+			shortUrl:       "metrics",
+			expectedCode:   http.StatusBadRequest,
+			expectedErrMsg: "Alias metrics is reserved",
+			wantErr:        true,
+		},
+		{
+			name:           "Reserved alias readyz",
+			url:            "http://example.com",
+			shortUrl:       "readyz",
+			expectedCode:   http.StatusBadRequest,
+			expectedErrMsg: "Alias readyz is reserved",
+			wantErr:        true,
+		},
+		{
+			name:           "Reserved alias top",
+			url:            "http://example.com",
+			shortUrl:       "top",
+			expectedCode:   http.StatusBadRequest,
+			expectedErrMsg: "Alias top is reserved",
+			wantErr:        true,
+		},
+		{
+			name:         "Alias differing from a reserved one in case",
+			url:          "http://example.com",
+			shortUrl:     "Top",
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Alias live",
+			url:          "http://example.com",
+			shortUrl:     "live",
+			expectedCode: http.StatusCreated,
+		},
 		{
 			name:           "SaveURL Error",
 			shortUrl:       "test_alias",
@@ -72,6 +114,15 @@ func TestSaveURL(t *testing.T) {
 			mockError:      errors.New("unexpected error"),
 			wantErr:        true,
 		},
+		{
+			name:           "Unsafe URL",
+			shortUrl:       "test_alias",
+			url:            "http://127.0.0.1/admin",
+			expectedErrMsg: "URL rejected: host 127.0.0.1 is a private or reserved address",
+			expectedCode:   http.StatusUnprocessableEntity,
+			mockError:      &screener.RejectError{Reason: "host 127.0.0.1 is a private or reserved address"},
+			wantErr:        true,
+		},
 		{
 			name:           "Empty request body",
 			expectedErrMsg: "Request.url:url is a required field",
@@ -101,7 +152,7 @@ func TestSaveURL(t *testing.T) {
 			rec := httptest.NewRecorder()
 
 			c := e.NewContext(req, rec)
-			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc)
+			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc, nil)
 			err := s.HandleURLSave(c)
 
 			if tt.wantErr {
@@ -185,7 +236,7 @@ func TestURLRedirect(t *testing.T) {
 			c.SetParamNames("short_url")
 			c.SetParamValues(tt.shortUrl)
 
-			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc)
+			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc, nil)
 			err := s.HandleURLRedirect(c)
 			if tt.wantErr {
 				respErr, ok := err.(*echo.HTTPError)
@@ -261,6 +312,11 @@ func TestGetURL(t *testing.T) {
 					Return(tt.mockReturn, tt.mockError).
 					Once()
 			}
+			if tt.mockReturn != nil {
+				mockSvc.On("Clicks", mock.Anything, tt.shortUrl).
+					Return(map[string]models.Clicks{tt.shortUrl: {Total: 12, Today: 3}}, nil).
+					Once()
+			}
 
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -271,7 +327,7 @@ func TestGetURL(t *testing.T) {
 			c.SetParamNames("short_url")
 			c.SetParamValues(tt.shortUrl)
 
-			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc)
+			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc, nil)
 
 			err := s.HandleURLGet(c)
 			if tt.wantErr {
@@ -301,6 +357,7 @@ func TestGetURL(t *testing.T) {
 				parsedTime, err := time.Parse(time.RFC3339Nano, resp["created_at"].(string))
 				assert.NoError(t, err)
 				assert.WithinDuration(t, tt.mockReturn.CreatedAt, parsedTime, time.Second)
+				assert.Equal(t, map[string]any{"total": float64(12), "today": float64(3)}, resp["clicks"])
 
 			} else {
 				assert.JSONEq(t, tt.expectedResp, rec.Body.String())
@@ -343,7 +400,7 @@ func TestDeleteURL(t *testing.T) {
 			name:           "Empty path alias",
 			shortUrl:       "",
 			expectedCode:   http.StatusBadRequest,
-			expectedErrMsg: "Short url cannot be empty",
+			expectedErrMsg: "Short URL cannot be empty",
 		},
 	}
 
@@ -365,7 +422,7 @@ func TestDeleteURL(t *testing.T) {
 			c.SetParamNames("short_url")
 			c.SetParamValues(tt.shortUrl)
 
-			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc)
+			s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mockSvc, nil)
 
 			err := s.HandleURLDelete(c)
 
@@ -381,3 +438,73 @@ func TestDeleteURL(t *testing.T) {
 		})
 	}
 }
+
+func TestURLInMemory(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{AliasLength: 6}
+	logger := slogdiscard.NewDiscardLogger()
+	broker := memorybroker.NewBroker(100)
+	svc := url.New(cfg, logger, memory.New(), broker, memory.NewCache(), nil, nil)
+	s := httpserver.New(cfg, logger, svc, nil)
+
+	call := func(method, shortUrl, body string, handler func(echo.Context) error) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(method, "/", bytes.NewReader([]byte(body)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("short_url")
+		c.SetParamValues(shortUrl)
+
+		return rec, handler(c)
+	}
+
+	rec, err := call(http.MethodPost, "", `{"url": "http://example.com", "short_url": "mem_alias"}`, s.HandleURLSave)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, err = call(http.MethodPost, "", `{"url": "http://example.org", "short_url": "mem_alias"}`, s.HandleURLSave)
+	var he *echo.HTTPError
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusBadRequest, he.Code)
+
+	rec, err = call(http.MethodGet, "mem_alias", "", s.HandleURLGet)
+	require.NoError(t, err)
+	var got models.URL
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "http://example.com", got.OriginalURL)
+
+	rec, err = call(http.MethodDelete, "mem_alias", "", s.HandleURLDelete)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, err = call(http.MethodGet, "mem_alias", "", s.HandleURLGet)
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusNotFound, he.Code)
+
+	require.NoError(t, svc.Wait(context.Background()))
+	var kinds []events.Kind
+	for _, msg := range broker.Messages(events.Topic) {
+		event, err := events.Decode(msg.Value)
+		require.NoError(t, err)
+		kinds = append(kinds, event.Kind)
+	}
+	assert.ElementsMatch(t, []events.Kind{events.KindCreated, events.KindDeleted}, kinds)
+}
+
+func TestReservedAliases(t *testing.T) {
+	t.Parallel()
+
+	s := httpserver.New(&config.Config{}, slogdiscard.NewDiscardLogger(), mocks.NewURLService(t), nil)
+
+	// Every static GET route of a single segment shadows the alias it is
+	// named after.
+	for _, route := range s.Handler().(*echo.Echo).Routes() {
+		alias := strings.TrimPrefix(route.Path, "/")
+		if route.Method != http.MethodGet || alias == "" || strings.ContainsAny(alias, "/:*") {
+			continue
+		}
+		assert.Contains(t, httpserver.ReservedAliases, alias)
+	}
+}
@@ -1,27 +1,53 @@
 package kafka
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"events"
 	"fmt"
+	"platform/kafkaheaders"
 	"strings"
+	"time"
 	"urlshortener/internal/config"
+	"urlshortener/internal/metrics"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const flushTimeout = 7000
+const (
+	metadataTimeout    = 5 * time.Second
+	minMetadataTimeout = 10 * time.Millisecond
+)
+
+var tracer = otel.Tracer("urlshortener/internal/transport/kafka")
 
 var errUnknownType = errors.New("unknown event type")
 
+// contentTypes maps config encodings to the content type sent in the
+// events.ContentTypeHeader header.
+var contentTypes = map[string]string{
+	config.EncodingJSON: events.ContentTypeJSON,
+	config.EncodingAvro: events.ContentTypeAvro,
+}
+
 type Producer struct {
-	cfg      *config.Config
-	producer *kafka.Producer
+	cfg         *config.Config
+	producer    *kafka.Producer
+	contentType string
 }
 
 func NewProducer(cfg *config.Config) (*Producer, error) {
 	const op = "kafka.NewProducer"
 
+	contentType, ok := contentTypes[cfg.MsgBroker.Encoding]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown encoding %q", op, cfg.MsgBroker.Encoding)
+	}
+
 	p, err := kafka.NewProducer(&kafka.ConfigMap{
 		"bootstrap.servers": strings.Join(cfg.MsgBroker.Addr, ","),
 	})
@@ -30,8 +56,9 @@ func NewProducer(cfg *config.Config) (*Producer, error) {
 	}
 
 	return &Producer{
-		cfg:      cfg,
-		producer: p,
+		cfg:         cfg,
+		producer:    p,
+		contentType: contentType,
 	}, nil
 }
 
@@ -39,10 +66,71 @@ func (p *Producer) Details() string {
 	return p.producer.String()
 }
 
-func (p *Producer) Produce(payload any, topic, key string) error {
+// Len returns the number of messages and requests waiting to be transmitted
+// to the broker as well as delivery reports queued for the application.
+func (p *Producer) Len() int {
+	return p.producer.Len()
+}
+
+// Ping requests cluster metadata to verify that at least one broker is
+// reachable within the deadline of ctx.
+func (p *Producer) Ping(ctx context.Context) error {
+	const op = "kafka.Ping"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// A deadline about to pass still gets a short wait, not a zero or
+	// negative timeout.
+	timeout := metadataTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = max(time.Until(deadline), minMetadataTimeout)
+	}
+
+	md, err := p.producer.GetMetadata(nil, false, int(timeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(md.Brokers) == 0 {
+		return fmt.Errorf("%s: no brokers available", op)
+	}
+
+	return nil
+}
+
+// Produce publishes event to events.Topic, keyed by its short url so the
+// events of one link stay ordered within a partition.
+func (p *Producer) Produce(ctx context.Context, event events.UrlEvent) error {
+	topic, key := events.Topic, event.ShortURL
+
+	ctx, span := tracer.Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingKafkaMessageKey(key),
+		),
+	)
+	defer span.End()
+
+	err := p.produce(ctx, event, topic, key)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		metrics.KafkaMessages.WithLabelValues(topic, metrics.ResultFailure).Inc()
+		return err
+	}
+
+	metrics.KafkaMessages.WithLabelValues(topic, metrics.ResultSuccess).Inc()
+	return nil
+}
+
+func (p *Producer) produce(ctx context.Context, event events.UrlEvent, topic, key string) error {
 	const op = "kafka.Produce"
 
-	msg, err := json.Marshal(payload)
+	msg, err := events.Marshal(event, p.contentType)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -54,7 +142,11 @@ func (p *Producer) Produce(payload any, topic, key string) error {
 		},
 		Value: msg,
 		Key:   []byte(key),
+		Headers: []kafka.Header{
+			{Key: events.ContentTypeHeader, Value: []byte(p.contentType)},
+		},
 	}
+	otel.GetTextMapPropagator().Inject(ctx, kafkaheaders.NewCarrier(&kafkaMsg.Headers))
 
 	kafkaChan := make(chan kafka.Event)
 	if err := p.producer.Produce(kafkaMsg, kafkaChan); err != nil {
@@ -78,6 +170,9 @@ func (p *Producer) Produce(payload any, topic, key string) error {
 	e := <-kafkaChan
 	switch ev := e.(type) {
 	case *kafka.Message:
+		if ev.TopicPartition.Error != nil {
+			return fmt.Errorf("%s: %w", op, ev.TopicPartition.Error)
+		}
 		return nil
 	case *kafka.Error:
 		return ev
@@ -86,7 +181,21 @@ func (p *Producer) Produce(payload any, topic, key string) error {
 	}
 }
 
-func (p *Producer) Close() {
-	p.producer.Flush(flushTimeout)
-	p.producer.Close()
+// Close waits for outstanding messages to be delivered, bounded by the
+// configured flush timeout and the deadline of ctx, then closes the producer.
+func (p *Producer) Close(ctx context.Context) error {
+	const op = "kafka.Close"
+
+	defer p.producer.Close()
+
+	timeout := time.Duration(p.cfg.MsgBroker.FlushTimeout) * time.Millisecond
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	if remaining := p.producer.Flush(int(timeout.Milliseconds())); remaining > 0 {
+		return fmt.Errorf("%s: %d messages not delivered", op, remaining)
+	}
+
+	return nil
 }
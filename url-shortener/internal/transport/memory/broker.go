@@ -0,0 +1,94 @@
+// Package memory implements the message broker in process memory. The last
+// messages of each topic are kept so tests and local runs can inspect what
+// would have been sent to Kafka.
+package memory
+
+import (
+	"context"
+	"events"
+	"fmt"
+	"sync"
+)
+
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+type Broker struct {
+	limit int
+
+	mu          sync.RWMutex
+	messages    map[string][]Message
+	subscribers []func(Message)
+}
+
+// NewBroker returns a broker keeping the last limit messages of each topic.
+func NewBroker(limit int) *Broker {
+	return &Broker{limit: max(limit, 1), messages: make(map[string][]Message)}
+}
+
+func (b *Broker) Produce(ctx context.Context, event events.UrlEvent) error {
+	const op = "memory.Produce"
+
+	value, err := events.Encode(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg := Message{
+		Topic: events.Topic,
+		Key:   event.ShortURL,
+		Value: value,
+	}
+
+	b.mu.Lock()
+	msgs := b.messages[events.Topic]
+	if len(msgs) == b.limit {
+		// Appending to the tail reallocates now and then, which releases
+		// the dropped messages.
+		msgs = msgs[1:]
+	}
+	b.messages[events.Topic] = append(msgs, msg)
+	subscribers := b.subscribers
+	b.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(msg)
+	}
+
+	return nil
+}
+
+// Subscribe calls fn with every message produced from now on, from the
+// goroutine producing it. It stands in for a consumer in tests running
+// several services in one process.
+func (b *Broker) Subscribe(fn func(Message)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Messages returns a copy of the messages kept for topic, oldest first.
+func (b *Broker) Messages(topic string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return append([]Message(nil), b.messages[topic]...)
+}
+
+// Len always reports an empty queue, since messages are stored as soon as
+// they are produced.
+func (b *Broker) Len() int {
+	return 0
+}
+
+func (b *Broker) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (b *Broker) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+	"events"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker_Limit(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(3)
+	for i := range 5 {
+		require.NoError(t, b.Produce(context.Background(), events.New(events.KindVisited, fmt.Sprint(i), time.Now())))
+	}
+
+	msgs := b.Messages(events.Topic)
+	keys := make([]string, len(msgs))
+	for i, msg := range msgs {
+		keys[i] = msg.Key
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, keys, "the oldest messages are dropped")
+}
@@ -0,0 +1,10 @@
+-- CHAR(16) cannot hold the longer aliases the up migration allows, so refuse
+-- to roll back while any exist instead of failing halfway through the ALTER.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM url WHERE length(short_url) > 16) THEN
+        RAISE EXCEPTION 'cannot narrow url.short_url to CHAR(16): aliases longer than 16 characters exist, delete or rename them first';
+    END IF;
+END $$;
+
+ALTER TABLE url ALTER COLUMN short_url TYPE CHAR(16);
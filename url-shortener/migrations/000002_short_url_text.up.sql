@@ -0,0 +1,3 @@
+-- CHAR(16) pads aliases with trailing spaces and caps their length. Casting
+-- to TEXT drops the padding of existing rows.
+ALTER TABLE url ALTER COLUMN short_url TYPE TEXT;
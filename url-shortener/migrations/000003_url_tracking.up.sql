@@ -0,0 +1,2 @@
+-- Links can opt out of visit tracking: their redirects publish no event.
+ALTER TABLE url ADD COLUMN tracking_disabled BOOLEAN NOT NULL DEFAULT FALSE;
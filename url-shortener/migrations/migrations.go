@@ -0,0 +1,15 @@
+// Package migrations embeds the schema migrations so the binary can apply
+// them without the migrate CLI or the source tree.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS holds the SQLite migrations under the sqlite directory.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN tracking_disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -1,36 +0,0 @@
-package logging
-
-import (
-	"log/slog"
-	"os"
-)
-
-const (
-	envLocal = "local"
-	envProd  = "prod"
-)
-
-func SetupLogger(env string) *slog.Logger {
-	var logger *slog.Logger
-
-	switch env {
-	case envLocal:
-		logger = slog.New(slog.NewTextHandler(
-			os.Stdout,
-			&slog.HandlerOptions{
-				AddSource: false,
-				Level:     slog.LevelDebug,
-			}),
-		)
-	case envProd:
-		logger = slog.New(slog.NewJSONHandler(
-			os.Stdout,
-			&slog.HandlerOptions{
-				AddSource: true,
-				Level:     slog.LevelInfo,
-			}),
-		)
-	}
-
-	return logger
-}
@@ -0,0 +1,77 @@
+// Package testserver runs url-shortener in-process for end-to-end tests that
+// drive it together with other services. It serves the real HTTP API on top
+// of in-memory storage, cache and broker.
+package testserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"urlshortener/internal/config"
+	"urlshortener/internal/repository/memory"
+	"urlshortener/internal/services/screener"
+	"urlshortener/internal/services/url"
+	"urlshortener/internal/services/userinfo"
+	httpserver "urlshortener/internal/transport/http"
+	memorybroker "urlshortener/internal/transport/memory"
+	slogdiscard "urlshortener/internal/utils/logger/handlers"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Options struct {
+	// GeoAPIAddress serves the geo lookups of visits.
+	GeoAPIAddress string
+	// OnMessage receives every message produced to the url_events topic,
+	// keyed by short url, from the goroutine producing it.
+	OnMessage func(key string, value []byte)
+}
+
+type Server struct {
+	// URL is the base url of the HTTP API.
+	URL string
+
+	service *url.URLService
+}
+
+// New starts a server that is stopped when t ends.
+func New(t testing.TB, opts Options) *Server {
+	cfg := &config.Config{
+		AppName:       "tiny-e2e",
+		AliasLength:   6,
+		GeoAPIAddress: opts.GeoAPIAddress,
+		Cache:         config.Cache{TTL: time.Minute},
+		Screener:      config.Screener{AllowedSchemes: []string{"http", "https"}},
+	}
+	logger := slogdiscard.NewDiscardLogger()
+
+	sc, err := screener.New(cfg, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { sc.Close() })
+
+	bots, err := userinfo.NewBots(cfg.Bots)
+	require.NoError(t, err)
+
+	broker := memorybroker.NewBroker(100)
+	if opts.OnMessage != nil {
+		broker.Subscribe(func(msg memorybroker.Message) { opts.OnMessage(msg.Key, msg.Value) })
+	}
+
+	us := userinfo.New(cfg.GeoAPIAddress, config.Privacy{IPMode: config.IPModeFull}, bots, config.Traffic{})
+	service := url.New(cfg, logger, memory.New(), broker, memory.NewCache(), us, sc)
+
+	srv := httptest.NewServer(httpserver.New(cfg, logger, service, nil).Handler())
+	t.Cleanup(srv.Close)
+
+	return &Server{URL: srv.URL, service: service}
+}
+
+// Settle waits for the events published in the background, which are handed
+// to OnMessage by the time it returns.
+func (s *Server) Settle(t testing.TB) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, s.service.Wait(ctx))
+}